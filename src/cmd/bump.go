@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/setter"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createBumpCommand creates the 'bump' subcommand
+func createBumpCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "bump (TYPE NAME | TYPE/NAME) (major | minor | patch)",
+		Short: "Set the next semantic version of a resource's image tag",
+		Long: `Set the next major, minor or patch version of the current image tag.
+
+A "v" prefix and variant suffixes such as "-alpine" are kept. With --pre the
+next version becomes a pre-release, and bumping a pre-release again increments
+its number. All flags of set are supported, use --verify to refuse tags that do
+not exist in the registry.
+
+Examples:
+  # v1.4.2 -> v1.4.3
+  kubectl image bump deploy/myapp patch
+
+  # v1.4.2 -> v1.5.0-rc.1, and v1.5.0-rc.1 -> v1.5.0-rc.2 when run again
+  kubectl image bump deploy/myapp minor --pre rc
+
+  # Only print the next tag
+  kubectl image bump deploy/myapp major --dry-run
+
+  # Check the tag was pushed, then wait for the rollout
+  kubectl image bump deployment myapp patch --verify --wait
+`,
+		Args:              cobra.RangeArgs(2, 3),
+		ValidArgsFunction: completeResourceArgs(&options, "major", "minor", "patch"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBumpCommand(cmd, &options, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of the resource (defaults to the current kubectl context namespace)")
+	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Container name to update (if not specified, updates first container)")
+	cmd.RegisterFlagCompletionFunc("container", completeContainers(&options))
+	cmd.Flags().StringVar(&options.BumpPreRelease, "pre", "", "Make the next version a pre-release with this name, e.g. rc")
+	cmd.Flags().BoolVar(&options.VerifyImage, "verify", false, "Verify that the new image exists in the registry before updating")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only print the tag that would be set, without updating the resource")
+	addConfirmFlags(cmd, &options)
+	addWaitFlags(cmd, &options)
+
+	return cmd
+}
+
+// runBumpCommand handles the bump command execution
+func runBumpCommand(cmd *cobra.Command, options *types.Options, args []string) error {
+	// Parse arguments
+	resourceType, resourceName, rest, err := parseResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("bump level is required: major, minor or patch")
+	}
+	options.ResourceType = resourceType
+	options.ResourceName = resourceName
+	options.BumpLevel = rest[0]
+
+	return executeBumpCommand(cmd, options)
+}
+
+// executeBumpCommand executes the bump command
+func executeBumpCommand(cmd *cobra.Command, options *types.Options) error {
+	// Connect to the current kubectl context, in its namespace unless given explicitly.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster("", options.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	options.In = bufio.NewReader(cmd.InOrStdin())
+	options.ErrOut = cmd.ErrOrStderr()
+	if !options.DryRun {
+		options.Out = cmd.OutOrStdout()
+	}
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateBump(); err != nil {
+		return err
+	}
+
+	// Fail before touching anything when the policies cannot be loaded
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	printTarget(cmd.ErrOrStderr(), options, cfg)
+
+	// Report every missing permission before changing anything
+	if err := access.Check(cmd.Context(), options.Clientset, options.Namespace, access.Set(options, cfg)); err != nil {
+		return err
+	}
+
+	result, err := setter.New(options, cfg).Set(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), getter.ExtractTag(result.NewImage))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/completion"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// completeResourceArgs returns a ValidArgsFunction completing "TYPE NAME" or "TYPE/NAME" with
+// the resources of the cluster, followed by the values of the argument after the resource, if any
+func completeResourceArgs(options *types.Options, next ...string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		// Manifests, kustomize overlays and Helm values are not completed from the cluster
+		if len(options.Filenames) > 0 || options.Kustomize != "" || options.HelmValues != "" {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		if len(args) == 0 {
+			resourceType, _, found := strings.Cut(toComplete, "/")
+			if !found {
+				return completion.ResourceTypes(), cobra.ShellCompDirectiveNoFileComp
+			}
+			names := completeResourceNames(cmd, options, resourceType)
+			for i, name := range names {
+				names[i] = resourceType + "/" + name
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		_, _, rest, err := parseResourceArgs(args)
+		if err != nil {
+			// Only the type was given so far
+			return completeResourceNames(cmd, options, args[0]), cobra.ShellCompDirectiveNoFileComp
+		}
+		if len(rest) == 0 {
+			return next, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeContainers completes --container with the containers of the resource given as arguments
+func completeContainers(options *types.Options) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		lookup, ok := completionTarget(options, args)
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		containers, err := completion.New(lookup).Containers(cmd.Context())
+		if err != nil {
+			cobra.CompDebugln(err.Error(), true)
+		}
+		return containers, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeTags completes --tag with the registry tags of the image the resource given as
+// arguments runs, in the container chosen with --container
+func completeTags(options *types.Options) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		lookup, ok := completionTarget(options, args)
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names, err := completion.New(lookup).Tags(cmd.Context())
+		if err != nil {
+			cobra.CompDebugln(err.Error(), true)
+		}
+		// Keep the registry order, newest version first
+		return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	}
+}
+
+// completeResourceNames returns the names of the resources of a type, nothing when they cannot be listed
+func completeResourceNames(cmd *cobra.Command, options *types.Options, resourceType string) []string {
+	names, err := completion.New(completionOptions(options)).ResourceNames(cmd.Context(), resourceType)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+	}
+	return names
+}
+
+// completionTarget returns the options to look up the resource given as arguments with,
+// false when there is no complete resource or it is not in the cluster
+func completionTarget(options *types.Options, args []string) (*types.Options, bool) {
+	if len(options.Filenames) > 0 || options.Kustomize != "" || options.HelmValues != "" {
+		return nil, false
+	}
+	resourceType, resourceName, _, err := parseResourceArgs(args)
+	if err != nil {
+		return nil, false
+	}
+	lookup := completionOptions(options)
+	lookup.ResourceType = resourceType
+	lookup.ResourceName = resourceName
+	return lookup, true
+}
+
+// completionOptions returns the options completions are looked up with: the context and
+// namespace the command reads the resource from, and the registry settings
+func completionOptions(options *types.Options) *types.Options {
+	lookup := &types.Options{
+		Namespace:     options.Namespace,
+		ContainerName: options.ContainerName,
+		PlainHTTP:     options.PlainHTTP,
+	}
+	// promote reads the resource from its source
+	if options.FromContext != "" || options.FromNamespace != "" {
+		lookup.Context = options.FromContext
+		lookup.Namespace = options.FromNamespace
+	}
+	return lookup
+}
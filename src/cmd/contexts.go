@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/fanout"
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/setter"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// addContextsFlags adds the flags running a command in several kubeconfig contexts
+func addContextsFlags(cmd *cobra.Command, options *types.Options) {
+	cmd.Flags().StringSliceVar(&options.Contexts, "contexts", nil, "Kubeconfig contexts to run in at the same time, names or globs")
+	cmd.Flags().StringVar(&options.AllContexts, "all-contexts", "", "Run in every kubeconfig context, or in those matching a glob given as --all-contexts=GLOB")
+	cmd.Flags().Lookup("all-contexts").NoOptDefVal = "*"
+}
+
+// multipleContexts reports whether the command runs in several kubeconfig contexts
+func multipleContexts(options *types.Options) bool {
+	return len(options.Contexts) > 0 || options.AllContexts != ""
+}
+
+// targetContexts returns the kubeconfig contexts selected by --contexts or --all-contexts
+func targetContexts(options *types.Options) ([]string, error) {
+	patterns := options.Contexts
+	if options.AllContexts != "" {
+		patterns = []string{options.AllContexts}
+	}
+	return client.Contexts(patterns)
+}
+
+// connectContext returns a copy of the options connected to the cluster of a context,
+// in --namespace or the namespace the context defaults to
+func connectContext(options *types.Options, contextName string) (*types.Options, error) {
+	cluster, err := client.NewCluster(contextName, options.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	contextOptions := *options
+	contextOptions.Clientset = cluster.Clientset
+	contextOptions.Namespace = cluster.Namespace
+	contextOptions.Context = contextName
+	return &contextOptions, nil
+}
+
+// contextRow is the result of a command in one context
+type contextRow struct {
+	Namespace string
+	Container string
+	OldImage  string
+	NewImage  string
+}
+
+// executeGetContextsCommand gets the image in several kubeconfig contexts at the same time
+func executeGetContextsCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateGet(); err != nil {
+		return err
+	}
+
+	contexts, err := targetContexts(options)
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[string]*contextRow, len(contexts))
+	for _, name := range contexts {
+		rows[name] = &contextRow{}
+	}
+	results := fanout.Run(cmd.Context(), contexts, false, func(ctx context.Context, contextName string) error {
+		row := rows[contextName]
+		contextOptions, err := connectContext(options, contextName)
+		if err != nil {
+			return err
+		}
+		row.Namespace = contextOptions.Namespace
+
+		if err := access.Check(ctx, contextOptions.Clientset, contextOptions.Namespace, access.Get(contextOptions)); err != nil {
+			return err
+		}
+		result, err := getter.New(contextOptions).Get(ctx)
+		if err != nil {
+			return err
+		}
+		row.NewImage = result.Image
+		if options.TagOnly {
+			row.NewImage = result.Tag
+		}
+		return nil
+	})
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	column := "IMAGE"
+	if options.TagOnly {
+		column = "TAG"
+	}
+	fmt.Fprintf(tw, "CONTEXT\tNAMESPACE\t%s\tERROR\n", column)
+	for _, result := range results {
+		row := rows[result.Context]
+		message := ""
+		if result.Err != nil {
+			message = firstLine(result.Err.Error())
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Context, orDash(row.Namespace), orDash(row.NewImage), orDash(message))
+	}
+	tw.Flush()
+
+	return contextsError(cmd.ErrOrStderr(), results)
+}
+
+// executeSetContextsCommand sets the image in several kubeconfig contexts, at the same time
+// or one after the other with --sequential
+func executeSetContextsCommand(cmd *cobra.Command, options *types.Options, cfg *config.Config) error {
+	// A sequential rollout moves to the next cluster once the current one finished rolling out
+	if options.Sequential && !options.DryRun {
+		options.Wait = true
+	}
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateSet(); err != nil {
+		return err
+	}
+
+	contexts, err := targetContexts(options)
+	if err != nil {
+		return err
+	}
+
+	// Sequential prompts share one reader, so answers piped together reach each of them
+	in := bufio.NewReader(cmd.InOrStdin())
+	rows := make(map[string]*contextRow, len(contexts))
+	for _, name := range contexts {
+		rows[name] = &contextRow{}
+	}
+	results := fanout.Run(cmd.Context(), contexts, options.Sequential, func(ctx context.Context, contextName string) error {
+		row := rows[contextName]
+		contextOptions, err := connectContext(options, contextName)
+		if err != nil {
+			return err
+		}
+		row.Namespace = contextOptions.Namespace
+
+		if options.Sequential {
+			// Progress and prompts of one cluster at a time can be shown as usual
+			fmt.Fprintf(cmd.ErrOrStderr(), "\n==> %s\n", contextName)
+			contextOptions.In = in
+			contextOptions.ErrOut = cmd.ErrOrStderr()
+			if !options.DryRun {
+				contextOptions.Out = cmd.OutOrStdout()
+			}
+		} else {
+			// Protected clusters cannot be prompted for in parallel, they need --yes
+			contextOptions.In = nil
+			contextOptions.Out = nil
+		}
+		printTarget(cmd.ErrOrStderr(), contextOptions, cfg)
+
+		if err := access.Check(ctx, contextOptions.Clientset, contextOptions.Namespace, access.Set(contextOptions, cfg)); err != nil {
+			return err
+		}
+		result, err := setter.New(contextOptions, cfg).Set(ctx)
+		if result != nil {
+			row.Container = result.Container
+			row.OldImage = result.OldImage
+			row.NewImage = result.NewImage
+		}
+		return err
+	})
+
+	if options.Sequential {
+		fmt.Fprintln(cmd.ErrOrStderr())
+	}
+	printSetContexts(cmd.OutOrStdout(), results, rows, options.DryRun)
+	return contextsError(cmd.ErrOrStderr(), results)
+}
+
+// printSetContexts prints the outcome of set in every context
+func printSetContexts(out io.Writer, results []fanout.Result, rows map[string]*contextRow, dryRun bool) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTEXT\tNAMESPACE\tCONTAINER\tOLD IMAGE\tNEW IMAGE\tRESULT")
+	for _, result := range results {
+		row := rows[result.Context]
+		outcome := "updated"
+		switch {
+		case result.Skipped:
+			outcome = "skipped"
+		case result.Err != nil:
+			outcome = "failed: " + firstLine(result.Err.Error())
+		case dryRun:
+			outcome = "dry-run"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Context, orDash(row.Namespace), orDash(row.Container),
+			orDash(row.OldImage), orDash(row.NewImage), outcome)
+	}
+	tw.Flush()
+}
+
+// contextsError combines the failures of all contexts into the exit status.
+// Errors spanning several lines, such as missing permissions, are printed in full.
+func contextsError(out io.Writer, results []fanout.Result) error {
+	for _, result := range results {
+		if result.Err != nil && strings.Contains(result.Err.Error(), "\n") {
+			fmt.Fprintf(out, "\n%s: %v\n", result.Context, result.Err)
+		}
+	}
+	failed, skipped := fanout.Count(results)
+	switch {
+	case skipped > 0:
+		return fmt.Errorf("%d of %d contexts failed, %d skipped", failed, len(results), skipped)
+	case failed > 0:
+		return fmt.Errorf("%d of %d contexts failed", failed, len(results))
+	}
+	return nil
+}
+
+// firstLine returns the first line of a message
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
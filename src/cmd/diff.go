@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/diff"
+	"github.com/reedchan7/kubectl-image/src/pkg/inventory"
+	"github.com/reedchan7/kubectl-image/src/pkg/lockfile"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// exitDrift is the exit code when the compared sides differ
+const exitDrift = 2
+
+// createDiffCommand creates the 'diff' subcommand
+func createDiffCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "diff --left CONTEXT/NAMESPACE --right CONTEXT/NAMESPACE",
+		Short: "Compare the images of two namespaces or clusters",
+		Long: `Compare the images of the deployments in two namespaces or clusters.
+
+Deployments are matched by name and their containers compared by repository,
+tag and the digest the pods run. Each side is CONTEXT/NAMESPACE; leave out the
+context for the current context ("/shop") or the namespace for the context's
+namespace ("prod" or "prod/"). A side may also be an image lock file written by
+the export command.
+
+Exits with code 2 when differences are found, so CI can detect drift.
+
+Examples:
+  # Compare staging and prod
+  kubectl image diff --left staging/shop --right prod/shop
+
+  # Two namespaces of the current cluster, only the frontend, as JSON
+  kubectl image diff --left /qa --right /prod -l tier=frontend -o json
+
+  # What changed in prod since the lock file was exported
+  kubectl image diff --left images.lock.yaml --right prod/shop
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeDiffCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Left, "left", "", "Left side as CONTEXT/NAMESPACE or an image lock file")
+	cmd.Flags().StringVar(&options.Right, "right", "", "Right side as CONTEXT/NAMESPACE or an image lock file")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Only compare deployments matching this label selector")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format: table or json")
+
+	return cmd
+}
+
+// executeDiffCommand executes the diff command
+func executeDiffCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateDiff(); err != nil {
+		return err
+	}
+
+	left, err := collectSide(cmd, options, options.Left)
+	if err != nil {
+		return err
+	}
+	right, err := collectSide(cmd, options, options.Right)
+	if err != nil {
+		return err
+	}
+
+	differences := diff.Compare(left, right)
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		report := struct {
+			Left        string            `json:"left"`
+			Right       string            `json:"right"`
+			Differences []diff.Difference `json:"differences"`
+		}{options.Left, options.Right, differences}
+		if report.Differences == nil {
+			report.Differences = []diff.Difference{}
+		}
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDiff(cmd.OutOrStdout(), differences)
+	}
+
+	if len(differences) > 0 {
+		return &types.ExitError{
+			Code:   exitDrift,
+			Reason: fmt.Sprintf("%d container image(s) differ between %s and %s", len(differences), options.Left, options.Right),
+		}
+	}
+	return nil
+}
+
+// collectSide reads the workloads of one CONTEXT/NAMESPACE side, or of an image lock file
+func collectSide(cmd *cobra.Command, options *types.Options, side string) ([]inventory.Workload, error) {
+	if info, err := os.Stat(side); err == nil && !info.IsDir() {
+		file, err := lockfile.Load(side, cmd.InOrStdin())
+		if err != nil {
+			return nil, err
+		}
+		return file.Inventory("", ""), nil
+	}
+
+	contextName, namespace := parseSide(side)
+	cluster, err := client.NewCluster(contextName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client for %s: %v", side, err)
+	}
+
+	sideOptions := *options
+	sideOptions.Namespace = cluster.Namespace
+	sideOptions.Clientset = cluster.Clientset
+
+	workloads, err := inventory.Collect(cmd.Context(), &sideOptions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", side, err)
+	}
+	return workloads, nil
+}
+
+// parseSide splits CONTEXT/NAMESPACE at the last slash, context names may contain slashes themselves
+func parseSide(side string) (string, string) {
+	slash := strings.LastIndex(side, "/")
+	if slash == -1 {
+		return side, ""
+	}
+	return side[:slash], side[slash+1:]
+}
+
+// printDiff prints the differences as a table
+func printDiff(out io.Writer, differences []diff.Difference) {
+	if len(differences) == 0 {
+		fmt.Fprintln(out, "No differences found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tDEPLOYMENT\tCONTAINER\tLEFT\tRIGHT\tCHANGED")
+	for _, d := range differences {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Status, d.Workload, d.Container,
+			describeImage(d.Left), describeImage(d.Right), orDash(strings.Join(d.Fields, ",")))
+	}
+	tw.Flush()
+}
+
+// describeImage formats a container image with a short digest for tables
+func describeImage(container *inventory.Container) string {
+	if container == nil {
+		return "-"
+	}
+
+	image := container.Image
+	if at := strings.Index(image, "@"); at != -1 {
+		image = image[:at]
+	}
+	if digest := container.Digest; digest != "" {
+		if len(digest) > len("sha256:")+12 {
+			digest = digest[:len("sha256:")+12]
+		}
+		image += "@" + digest
+	}
+	return image
+}
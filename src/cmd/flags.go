@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// addConfirmFlags adds the flags confirming updates of the cluster
+func addConfirmFlags(cmd *cobra.Command, options *types.Options) {
+	cmd.Flags().BoolVar(&options.Confirm, "confirm", false, "Show the change and ask before updating the deployment")
+	cmd.Flags().BoolVarP(&options.Yes, "yes", "y", false, "Update protected contexts and namespaces without typing the deployment name")
+}
+
+// addWaitFlags adds the rollout wait and progress output flags
+func addWaitFlags(cmd *cobra.Command, options *types.Options) {
+	cmd.Flags().BoolVarP(&options.Wait, "wait", "w", false, "Wait for the rollout to complete before returning")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 0, "Maximum time to wait for the rollout (defaults to the deployment's progressDeadlineSeconds)")
+	cmd.Flags().DurationVar(&options.PollInterval, "poll-interval", 5*time.Second, "Interval between rollout status checks")
+	cmd.Flags().DurationVar(&options.CleanupGrace, "cleanup-grace", 60*time.Second, "How long to wait for old pods to terminate once new pods are ready (0 waits until they are gone)")
+	cmd.Flags().StringVar(&options.WaitFor, "wait-for", string(types.WaitForCleanup), "When the rollout is considered done: ready, available or cleanup")
+	cmd.Flags().StringVar(&options.Progress, "progress", string(types.ProgressHuman), "Progress output format: human or json (newline-delimited JSON events)")
+	cmd.Flags().BoolVar(&options.NoEmoji, "no-emoji", false, "Use plain text markers instead of emoji in progress output")
+	cmd.Flags().BoolVar(&options.NoEmoji, "plain", false, "Alias for --no-emoji")
+}
@@ -3,8 +3,12 @@ package main
 import (
 	"fmt"
 
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
 	"github.com/reedchan7/kubectl-image/src/pkg/client"
 	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/helm"
+	"github.com/reedchan7/kubectl-image/src/pkg/kustomize"
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
 	"github.com/reedchan7/kubectl-image/src/pkg/types"
 	"github.com/reedchan7/kubectl-image/src/pkg/validator"
 	"github.com/spf13/cobra"
@@ -15,7 +19,7 @@ func createGetCommand() *cobra.Command {
 	var options types.Options
 
 	cmd := &cobra.Command{
-		Use:   "get RESOURCE_TYPE NAME",
+		Use:   "get (TYPE NAME | TYPE/NAME | --kustomize DIR IMAGE_NAME | --helm-values FILE --path PATH)",
 		Short: "Get the images of a Kubernetes resource",
 		Long: `Get the images of a Kubernetes resource such as deployment or pod.
 
@@ -25,42 +29,89 @@ Examples:
   
   # Get only the tag of the deployment's first image
   kubectl image get deploy myapp --tag
+
+  # Get the image of a specific container
+  kubectl image get deploy myapp --container sidecar
+
+  # Get the image in every cluster whose context matches a glob
+  kubectl image get deploy myapp --all-contexts='prod-*'
+
+  # Get the image from local manifests instead of the cluster
+  kubectl image get deploy myapp -f k8s/ -R
+
+  # Get the image a kustomize overlay renders after its images transformers
+  kubectl image get --kustomize overlays/prod myapp
+
+  # Get the image of a Helm values file
+  kubectl image get --helm-values values-prod.yaml --path app.image
 `,
-		Args: cobra.ExactArgs(2),
+		Args:              cobra.MaximumNArgs(2),
+		ValidArgsFunction: completeResourceArgs(&options),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runGetCommand(&options, args)
+			return runGetCommand(cmd, &options, args)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&options.TagOnly, "tag", "t", false, "Return only the image tag")
+	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Container name to get (if not specified, gets first container)")
+	cmd.RegisterFlagCompletionFunc("container", completeContainers(&options))
+	cmd.Flags().StringSliceVarP(&options.Filenames, "filename", "f", nil, "Manifest files or directories to read instead of the cluster, - for stdin")
+	cmd.Flags().BoolVarP(&options.Recursive, "recursive", "R", false, "Read the directories given with --filename recursively")
+	cmd.Flags().StringVarP(&options.Kustomize, "kustomize", "k", "", "Kustomization directory to read the effective image from instead of the cluster")
+	cmd.Flags().StringVar(&options.HelmValues, "helm-values", "", "Helm values file to read the image at --path from instead of the cluster")
+	cmd.Flags().StringVar(&options.ValuesPath, "path", "", "Dotted path of the image in the --helm-values file, e.g. app.image")
+	addContextsFlags(cmd, &options)
 
 	return cmd
 }
 
 // runGetCommand handles the get command execution
-func runGetCommand(options *types.Options, args []string) error {
+func runGetCommand(cmd *cobra.Command, options *types.Options, args []string) error {
 	// Parse arguments
-	options.ResourceType = args[0]
-	options.ResourceName = args[1]
+	if options.HelmValues != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %v, --path selects the image", args)
+		}
+		return executeGetHelmValuesCommand(cmd, options)
+	}
+	if options.Kustomize != "" {
+		if len(args) != 1 {
+			return fmt.Errorf("exactly one image name is required with --kustomize")
+		}
+		options.ImageName = args[0]
+		return executeGetKustomizeCommand(cmd, options)
+	}
+	resourceType, resourceName, rest, err := parseResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %v", rest)
+	}
+	options.ResourceType = resourceType
+	options.ResourceName = resourceName
 
-	return executeGetCommand(options)
+	return executeGetCommand(cmd, options)
 }
 
 // executeGetCommand executes the image get command
-func executeGetCommand(options *types.Options) error {
-	// Get current namespace from kubectl context
-	if ns, err := client.GetCurrentNamespace(); err == nil {
-		options.Namespace = ns
-	} else {
-		options.Namespace = "default"
+func executeGetCommand(cmd *cobra.Command, options *types.Options) error {
+	if len(options.Filenames) > 0 {
+		return executeGetManifestCommand(cmd, options)
+	}
+	if multipleContexts(options) {
+		return executeGetContextsCommand(cmd, options)
 	}
 
-	// Create Kubernetes client
-	clientset, err := client.NewClient()
+	// Connect to the current kubectl context, in its namespace.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster("", "")
 	if err != nil {
 		return fmt.Errorf("failed to create Kubernetes client: %v", err)
 	}
-	options.Clientset = clientset
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
 
 	// Validate input
 	v := validator.New(options)
@@ -68,7 +119,102 @@ func executeGetCommand(options *types.Options) error {
 		return err
 	}
 
+	if err := access.Check(cmd.Context(), options.Clientset, options.Namespace, access.Get(options)); err != nil {
+		return err
+	}
+
 	// Get images
 	g := getter.New(options)
-	return g.Get()
+	result, err := g.Get(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	printGetResult(cmd, options, result)
+	return nil
+}
+
+// executeGetManifestCommand gets the image from local manifest files, without connecting to the cluster
+func executeGetManifestCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateGet(); err != nil {
+		return err
+	}
+
+	files, err := manifest.Load(options.Filenames, options.Recursive, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	result, err := getter.New(options).GetManifest(files)
+	if err != nil {
+		return err
+	}
+
+	printGetResult(cmd, options, result)
+	return nil
+}
+
+// executeGetKustomizeCommand prints the effective images of a kustomization, without connecting to the cluster
+func executeGetKustomizeCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateGet(); err != nil {
+		return err
+	}
+
+	k, err := kustomize.Load(options.Kustomize)
+	if err != nil {
+		return err
+	}
+	images, err := k.Effective(options.ImageName)
+	if err != nil {
+		return err
+	}
+
+	// Bases may use the image in different versions, each one is printed
+	for _, image := range images {
+		if options.TagOnly {
+			image = getter.ExtractTag(image)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), image)
+	}
+	return nil
+}
+
+// executeGetHelmValuesCommand prints the image of a Helm values file, without connecting to the cluster
+func executeGetHelmValuesCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateGet(); err != nil {
+		return err
+	}
+
+	values, err := helm.Load(options.HelmValues)
+	if err != nil {
+		return err
+	}
+	image, err := values.Image(options.ValuesPath)
+	if err != nil {
+		return err
+	}
+
+	output := image.Image
+	if options.TagOnly {
+		output = getter.ExtractTag(output)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), output)
+	return nil
+}
+
+// printGetResult prints just the image string, or its tag, to stdout
+func printGetResult(cmd *cobra.Command, options *types.Options, result *getter.Result) {
+	output := result.Image
+	if options.TagOnly {
+		output = result.Tag
+	}
+	if output != "" {
+		fmt.Fprintln(cmd.OutOrStdout(), output)
+	}
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
+	"github.com/reedchan7/kubectl-image/src/pkg/fanout"
+	"github.com/reedchan7/kubectl-image/src/pkg/inventory"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createListCommand creates the 'list' subcommand
+func createListCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the images of all deployments",
+		Long: `List the image of every deployment container in a namespace, all namespaces or several clusters.
+
+Examples:
+  # Images of the current namespace
+  kubectl image list
+
+  # Frontend deployments of all namespaces as JSON
+  kubectl image list -A -l tier=frontend -o json
+
+  # The same namespace in every production cluster
+  kubectl image list -n shop --all-contexts='prod-*'
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeListCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to list (defaults to the current kubectl context namespace)")
+	cmd.Flags().BoolVarP(&options.AllNamespaces, "all-namespaces", "A", false, "List deployments of all namespaces")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Only list deployments matching this label selector")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format: table or json")
+	addContextsFlags(cmd, &options)
+
+	return cmd
+}
+
+// contextWorkloads are the deployments listed in one kubeconfig context
+type contextWorkloads struct {
+	Context   string               `json:"context"`
+	Workloads []inventory.Workload `json:"workloads"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// executeListCommand executes the list command
+func executeListCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateList(); err != nil {
+		return err
+	}
+
+	if multipleContexts(options) {
+		return executeListContextsCommand(cmd, options)
+	}
+
+	if err := connect(options); err != nil {
+		return err
+	}
+	workloads, err := listWorkloads(cmd.Context(), options)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(workloads)
+	}
+	printWorkloads(cmd.OutOrStdout(), []contextWorkloads{{Workloads: workloads}}, false)
+	return nil
+}
+
+// executeListContextsCommand lists the images in several kubeconfig contexts at the same time
+func executeListContextsCommand(cmd *cobra.Command, options *types.Options) error {
+	contexts, err := targetContexts(options)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]*contextWorkloads, len(contexts))
+	for _, name := range contexts {
+		listed[name] = &contextWorkloads{Context: name}
+	}
+	results := fanout.Run(cmd.Context(), contexts, false, func(ctx context.Context, contextName string) error {
+		contextOptions, err := connectContext(options, contextName)
+		if err != nil {
+			return err
+		}
+		// -A lists every namespace of every context, -n is already applied by connectContext
+		if options.AllNamespaces {
+			contextOptions.Namespace = ""
+		}
+		workloads, err := listWorkloads(ctx, contextOptions)
+		listed[contextName].Workloads = workloads
+		return err
+	})
+
+	all := make([]contextWorkloads, 0, len(results))
+	for _, result := range results {
+		entry := listed[result.Context]
+		if result.Err != nil {
+			entry.Error = firstLine(result.Err.Error())
+		}
+		all = append(all, *entry)
+	}
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(all); err != nil {
+			return err
+		}
+	} else {
+		printWorkloads(cmd.OutOrStdout(), all, true)
+	}
+	return contextsError(cmd.ErrOrStderr(), results)
+}
+
+// listWorkloads checks the permissions to list deployments, then lists them with their images
+func listWorkloads(ctx context.Context, options *types.Options) ([]inventory.Workload, error) {
+	if err := access.Check(ctx, options.Clientset, options.Namespace, access.List()); err != nil {
+		return nil, err
+	}
+	return inventory.Collect(ctx, options)
+}
+
+// printWorkloads prints one row per container, with a context column when listing several contexts
+func printWorkloads(out io.Writer, listed []contextWorkloads, showContext bool) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if showContext {
+		fmt.Fprint(tw, "CONTEXT\t")
+	}
+	fmt.Fprintln(tw, "NAMESPACE\tDEPLOYMENT\tCONTAINER\tIMAGE")
+	for _, entry := range listed {
+		if entry.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\tfailed: %s\n", entry.Context, entry.Error)
+			continue
+		}
+		for _, workload := range entry.Workloads {
+			for _, container := range workload.Containers {
+				if showContext {
+					fmt.Fprintf(tw, "%s\t", entry.Context)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", workload.Namespace, workload.Name, container.Name, container.Image)
+			}
+		}
+	}
+	tw.Flush()
+}
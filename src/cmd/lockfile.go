@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/apply"
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/diff"
+	"github.com/reedchan7/kubectl-image/src/pkg/inventory"
+	"github.com/reedchan7/kubectl-image/src/pkg/lockfile"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createExportCommand creates the 'export' subcommand
+func createExportCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write an image lock file of the deployments in a namespace",
+		Long: `Write an image lock file recording the image of every deployment container.
+
+Images are pinned to the digest the running pods report, so applying the lock
+file later restores exactly the same images even if tags were pushed again.
+
+Examples:
+  # Lock the images of a namespace
+  kubectl image export -n shop > images.lock.yaml
+
+  # Lock the frontend deployments of all namespaces
+  kubectl image export -A -l tier=frontend > frontend.lock.yaml
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeExportCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to export (defaults to the current kubectl context namespace)")
+	cmd.Flags().BoolVarP(&options.AllNamespaces, "all-namespaces", "A", false, "Export deployments of all namespaces")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Only export deployments matching this label selector")
+
+	return cmd
+}
+
+// executeExportCommand executes the export command
+func executeExportCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateExport(); err != nil {
+		return err
+	}
+
+	if err := connect(options); err != nil {
+		return err
+	}
+
+	workloads, err := inventory.Collect(cmd.Context(), options)
+	if err != nil {
+		return err
+	}
+
+	file, warnings := lockfile.Export(workloads)
+	for _, warning := range warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
+	return file.Write(cmd.OutOrStdout())
+}
+
+// createApplyCommand creates the 'apply' subcommand
+func createApplyCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "Set the images of an image lock file",
+		Long: `Set the images of all deployments in an image lock file.
+
+The changes are previewed first, then the deployments are updated in parallel,
+each with a single rollout, and a result is reported per deployment.
+Deployments without namespace in the lock file are looked up in --namespace.
+Deployments are updated in parallel and cannot be prompted for, so protected
+contexts and namespaces need --yes; --confirm asks once after the preview.
+
+Examples:
+  # Preview only
+  kubectl image apply -f images.lock.yaml --dry-run
+
+  # Look at the preview before anything is updated
+  kubectl image apply -f images.lock.yaml --confirm
+
+  # Restore the locked images and wait for every rollout
+  kubectl image apply -f images.lock.yaml --wait --parallel 8
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeApplyCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Filename, "filename", "f", "", "Lock file to apply, - for stdin")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of deployments without namespace in the lock file (defaults to the current kubectl context namespace)")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only show the preview, without updating deployments")
+	cmd.Flags().IntVar(&options.Concurrency, "parallel", 4, "Maximum number of deployments updated at the same time")
+	cmd.Flags().BoolVar(&options.VerifyImage, "verify", false, "Verify that the new images exist in the registry before updating")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format of the report: table or json")
+	addConfirmFlags(cmd, &options)
+	cmd.Flags().BoolVarP(&options.Wait, "wait", "w", false, "Wait for every rollout to complete before reporting")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 0, "Maximum time to wait for each rollout (defaults to the deployment's progressDeadlineSeconds)")
+	cmd.Flags().StringVar(&options.WaitFor, "wait-for", string(types.WaitForCleanup), "When a rollout is considered done: ready, available or cleanup")
+
+	return cmd
+}
+
+// executeApplyCommand executes the apply command
+func executeApplyCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateApply(); err != nil {
+		return err
+	}
+
+	// Fail before touching anything when the policies cannot be loaded
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	file, err := lockfile.Load(options.Filename, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	if err := connect(options); err != nil {
+		return err
+	}
+	options.In = bufio.NewReader(cmd.InOrStdin())
+	if !options.DryRun {
+		printTarget(cmd.ErrOrStderr(), options, cfg)
+	}
+
+	out := cmd.OutOrStdout()
+	jsonOutput := strings.ToLower(options.Output) == string(types.OutputJSON)
+	applier := apply.New(options, cfg, file)
+
+	items := applier.Plan(cmd.Context())
+	if !jsonOutput {
+		fmt.Fprintln(out, "Preview:")
+		printApplyPreview(out, items)
+	}
+
+	if !options.DryRun {
+		if options.Confirm {
+			if err := confirmApply(cmd, options.In, items); err != nil {
+				return err
+			}
+		}
+		if !jsonOutput {
+			fmt.Fprintln(out, "\nResult:")
+		}
+		items = applier.Apply(cmd.Context(), items)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(items); err != nil {
+			return err
+		}
+	} else if !options.DryRun {
+		printApplyReport(out, items)
+	}
+
+	if err := cmd.Context().Err(); err != nil {
+		return err
+	}
+	failed := 0
+	for _, item := range items {
+		if item.Status == apply.StatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deployments failed", failed, len(items))
+	}
+	return nil
+}
+
+// confirmApply asks once before the deployments of the preview are updated
+func confirmApply(cmd *cobra.Command, in *bufio.Reader, items []apply.Item) error {
+	pending := 0
+	for _, item := range items {
+		if item.Status == apply.StatusPending {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Update %d deployment(s)? [y/N]: ", pending)
+	answer, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	answer = strings.TrimSpace(answer)
+	if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		if errors.Is(err, io.EOF) && answer == "" {
+			fmt.Fprintln(cmd.ErrOrStderr())
+		}
+		return fmt.Errorf("apply cancelled, nothing was changed")
+	}
+	return nil
+}
+
+// printApplyPreview prints the image changes apply would make
+func printApplyPreview(out io.Writer, items []apply.Item) {
+	for _, item := range items {
+		switch item.Status {
+		case apply.StatusUnchanged:
+			fmt.Fprintf(out, "  %s/%s: unchanged\n", item.Namespace, item.Deployment)
+		case apply.StatusFailed:
+			fmt.Fprintf(out, "  %s/%s: %s\n", item.Namespace, item.Deployment, item.Error)
+		default:
+			fmt.Fprintf(out, "  %s/%s:\n", item.Namespace, item.Deployment)
+			for _, change := range item.Changes {
+				fmt.Fprintf(out, "    container %s:\n", change.Container)
+				fmt.Fprintf(out, "      - %s\n", change.OldImage)
+				fmt.Fprintf(out, "      + %s\n", change.NewImage)
+			}
+		}
+	}
+}
+
+// printApplyReport prints the outcome of every deployment
+func printApplyReport(out io.Writer, items []apply.Item) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tDEPLOYMENT\tRESULT\tCONTAINERS\tDURATION\tMESSAGE")
+	for _, item := range items {
+		duration := "-"
+		if item.Seconds > 0 {
+			duration = fmt.Sprintf("%.1fs", item.Seconds)
+		}
+		containers := make([]string, 0, len(item.Changes))
+		for _, change := range item.Changes {
+			containers = append(containers, change.Container)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", item.Namespace, item.Deployment, item.Status,
+			orDash(strings.Join(containers, ",")), duration, orDash(item.Error))
+	}
+	tw.Flush()
+}
+
+// createVerifyCommand creates the 'verify' subcommand
+func createVerifyCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "verify -f FILE",
+		Short: "Report drift between an image lock file and the cluster",
+		Long: `Compare the deployments of the cluster with an image lock file without changing anything.
+
+Containers are compared by repository, tag and digest like the diff command.
+Exits with code 2 when the cluster drifted from the lock file.
+
+Examples:
+  kubectl image verify -f images.lock.yaml
+  kubectl image verify -f images.lock.yaml -o json
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeVerifyCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Filename, "filename", "f", "", "Lock file to verify, - for stdin")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of deployments without namespace in the lock file (defaults to the current kubectl context namespace)")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Only compare deployments matching this label selector")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format: table or json")
+
+	return cmd
+}
+
+// executeVerifyCommand executes the verify command
+func executeVerifyCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateApply(); err != nil {
+		return err
+	}
+
+	file, err := lockfile.Load(options.Filename, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	if err := connect(options); err != nil {
+		return err
+	}
+
+	var differences []diff.Difference
+	for _, namespace := range file.Namespaces(options.Namespace) {
+		namespaceOptions := *options
+		namespaceOptions.Namespace = namespace
+		workloads, err := inventory.Collect(cmd.Context(), &namespaceOptions)
+		if err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		for _, d := range diff.Compare(file.Inventory(namespace, options.Namespace), workloads) {
+			d.Workload = namespace + "/" + d.Workload
+			differences = append(differences, d)
+		}
+	}
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if differences == nil {
+			differences = []diff.Difference{}
+		}
+		if err := encoder.Encode(differences); err != nil {
+			return err
+		}
+	} else {
+		printDiff(cmd.OutOrStdout(), differences)
+	}
+
+	if len(differences) > 0 {
+		return &types.ExitError{
+			Code:   exitDrift,
+			Reason: fmt.Sprintf("%d container image(s) drifted from %s", len(differences), options.Filename),
+		}
+	}
+	return nil
+}
+
+// connect fills in the current namespace and creates the Kubernetes client
+func connect(options *types.Options) error {
+	// Client, namespace and context name come from the same kubeconfig
+	cluster, err := client.NewCluster("", options.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	// All namespaces are scanned unless a namespace is given explicitly
+	if !options.AllNamespaces {
+		options.Namespace = cluster.Namespace
+	}
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	return nil
+}
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
 )
 
 func main() {
 	if err := Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// Follow the shell convention for processes stopped by SIGINT
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
+		var exitErr *types.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
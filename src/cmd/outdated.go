@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/outdated"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// exitOutdated is the exit code when images with an update of at least the --fail-on level are found
+const exitOutdated = 2
+
+// updateRank orders update levels for --fail-on
+var updateRank = map[types.UpdateLevel]int{
+	types.UpdatePatch: 1,
+	types.UpdateMinor: 2,
+	types.UpdateMajor: 3,
+}
+
+// createOutdatedCommand creates the 'outdated' subcommand
+func createOutdatedCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "Compare the running image tags against the registry",
+		Long: `Compare the image tags of all deployments against the tags in their registries.
+
+For every container running a semantic version the newest patch, minor and
+major versions are shown. Registry lookups are cached per repository and run
+concurrently, limited by --concurrency and --registry-qps.
+
+Exit codes: 0 when nothing is outdated at the --fail-on level, 1 on errors,
+including failed registry lookups, 2 when outdated images are found.
+
+Examples:
+  # Outdated images in the current namespace
+  kubectl image outdated
+
+  # Scan the whole cluster and fail a CI job on minor or major updates
+  kubectl image outdated -A --fail-on minor
+
+  # Machine readable report, including images that are up to date
+  kubectl image outdated -n prod -o json --all
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeOutdatedCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to scan (defaults to the current kubectl context namespace)")
+	cmd.Flags().BoolVarP(&options.AllNamespaces, "all-namespaces", "A", false, "Scan deployments in all namespaces")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format: table or json")
+	cmd.Flags().BoolVar(&options.ShowAll, "all", false, "Also show images that are up to date or could not be compared")
+	cmd.Flags().StringVar(&options.FailOn, "fail-on", "", "Exit with code 2 when an update of at least this level exists: patch, minor or major")
+	cmd.Flags().IntVar(&options.Concurrency, "concurrency", 8, "Maximum number of parallel registry lookups")
+	cmd.Flags().Float64Var(&options.RegistryQPS, "registry-qps", 10, "Maximum registry lookups per second")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+
+	return cmd
+}
+
+// executeOutdatedCommand executes the outdated command
+func executeOutdatedCommand(cmd *cobra.Command, options *types.Options) error {
+	// Connect to the current kubectl context, in its namespace unless given explicitly.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster("", options.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	options.ErrOut = cmd.ErrOrStderr()
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateOutdated(); err != nil {
+		return err
+	}
+
+	report, err := outdated.New(options).Check(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printOutdated(cmd.OutOrStdout(), report, options)
+	}
+
+	for _, image := range report.Images {
+		if image.Error != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s/%s container %s: %s\n", image.Namespace, image.Deployment, image.Container, image.Error)
+		}
+	}
+
+	// An incomplete report must not pass a CI gate, e.g. when the registry is unreachable
+	if report.Errors > 0 {
+		return &types.ExitError{
+			Code:   1,
+			Reason: fmt.Sprintf("%d of %d image(s) could not be checked against their registry", report.Errors, len(report.Images)),
+		}
+	}
+
+	if options.FailOn == "" {
+		return nil
+	}
+	threshold := updateRank[types.ValidUpdateLevels[strings.ToLower(options.FailOn)]]
+	failed := 0
+	for _, image := range report.Images {
+		if image.Update != "" && updateRank[image.Update] >= threshold {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &types.ExitError{
+			Code:   exitOutdated,
+			Reason: fmt.Sprintf("%d image(s) have a %s update or larger available", failed, strings.ToLower(options.FailOn)),
+		}
+	}
+	return nil
+}
+
+// printOutdated prints the report as a table, only outdated images unless --all is given
+func printOutdated(out io.Writer, report *outdated.Report, options *types.Options) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	header := "DEPLOYMENT\tCONTAINER\tREPOSITORY\tCURRENT\tPATCH\tMINOR\tMAJOR"
+	if options.AllNamespaces {
+		header = "NAMESPACE\t" + header
+	}
+	fmt.Fprintln(tw, header)
+
+	rows := 0
+	for _, image := range report.Images {
+		if image.Update == "" && !options.ShowAll {
+			continue
+		}
+		rows++
+
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s", image.Deployment, image.Container, image.Repository,
+			image.Current, orDash(image.Patch), orDash(image.Minor), orDash(image.Major))
+		if image.Skipped != "" || image.Error != "" {
+			row = fmt.Sprintf("%s\t%s\t%s\t%s\t?\t?\t?", image.Deployment, image.Container, image.Repository, image.Current)
+		}
+		if options.AllNamespaces {
+			row = image.Namespace + "\t" + row
+		}
+		fmt.Fprintln(tw, row)
+	}
+
+	if rows == 0 {
+		fmt.Fprintf(out, "No outdated images found (%d containers checked)\n", len(report.Images))
+		return
+	}
+	tw.Flush()
+}
+
+// orDash returns "-" for empty table cells
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
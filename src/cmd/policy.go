@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createPolicyCommand creates the 'policy' subcommand
+func createPolicyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Work with the image policies of the configuration file",
+	}
+
+	cmd.AddCommand(createPolicyTestCommand())
+
+	return cmd
+}
+
+// createPolicyTestCommand creates the 'policy test' subcommand
+func createPolicyTestCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "test -f SAMPLES",
+		Short: "Evaluate the image policies against sample updates without a cluster",
+		Long: `Evaluate the image policies, including their CEL rules, against sample image updates.
+
+Each sample describes an update the way set would see it. Samples may expect
+"allow" or "deny"; the command fails when a sample gets another outcome or a
+rule cannot be evaluated, so policies can be tested in CI.
+
+  - name: feature tag in dev
+    context: dev
+    namespace: team-a
+    namespaceLabels: {env: dev}
+    workload: {kind: Deployment, name: api, labels: {branch: feature-x}}
+    container: api
+    oldImage: ghcr.io/acme/api:feature-x-41
+    newImage: ghcr.io/acme/api:feature-x-42
+    expect: allow
+
+Examples:
+  kubectl image policy test -f samples.yaml
+  kubectl image policy test -f samples.yaml --config ./config.yaml -o json
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executePolicyTestCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Filename, "filename", "f", "", "Samples to evaluate, - for stdin")
+	cmd.Flags().StringVar(&options.ConfigFile, "config", "", "Configuration file to test instead of the default one")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", string(types.OutputTable), "Output format: table or json")
+
+	return cmd
+}
+
+// executePolicyTestCommand executes the policy test command
+func executePolicyTestCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidatePolicyTest(); err != nil {
+		return err
+	}
+
+	var cfg *config.Config
+	var err error
+	if options.ConfigFile != "" {
+		cfg, err = config.LoadFile(options.ConfigFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return err
+	}
+
+	samples, err := policy.LoadSamples(options.Filename, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	outcomes := policy.Test(cfg.Policies, samples)
+
+	if strings.ToLower(options.Output) == string(types.OutputJSON) {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(outcomes); err != nil {
+			return err
+		}
+	} else {
+		printPolicyOutcomes(cmd.OutOrStdout(), outcomes)
+	}
+
+	failed := 0
+	for _, outcome := range outcomes {
+		if !outcome.Passed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d samples failed", failed, len(outcomes))
+	}
+	return nil
+}
+
+// printPolicyOutcomes prints the outcome of every sample
+func printPolicyOutcomes(out io.Writer, outcomes []policy.Outcome) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SAMPLE\tIMAGE\tRESULT\tEXPECTED\tPOLICY\tRULE\tMESSAGE")
+	for _, outcome := range outcomes {
+		result, message := "allow", outcome.Message
+		switch {
+		case outcome.Error != "":
+			result, message = "error", outcome.Error
+		case !outcome.Allowed:
+			result = "deny"
+		}
+		if !outcome.Passed {
+			result += " (FAIL)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", outcome.Sample, outcome.Image, result,
+			orDash(outcome.Expect), orDash(outcome.Policy), orDash(outcome.Rule), orDash(message))
+	}
+	tw.Flush()
+}
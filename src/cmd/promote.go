@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/promote"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createPromoteCommand creates the 'promote' subcommand
+func createPromoteCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "promote (TYPE NAME | TYPE/NAME)",
+		Short: "Copy the images of a resource from one namespace or cluster to another",
+		Long: `Copy the images a resource runs in one namespace or cluster to the same resource in another.
+
+The source images are pinned to the digests its pods run, so the target gets
+exactly what was tested even when tags move. A diff per container is shown
+before all containers are updated in a single rollout.
+
+Examples:
+  # Put whatever staging runs into prod and wait for the rollout
+  kubectl image promote deploy/myapp --from-context staging --to-context prod --wait
+
+  # Between namespaces of the current cluster, only show the diff
+  kubectl image promote deploy/myapp --from-namespace qa --to-namespace prod --dry-run
+
+  # Skip the confirmation of a protected target, e.g. in CI
+  kubectl image promote deploy/myapp --from-context staging --to-context prod --yes
+
+  # Promote a single container by tag instead of digest
+  kubectl image promote deploy/myapp --from-context staging --to-context prod -c app --digest=false
+`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeResourceArgs(&options),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPromoteCommand(cmd, &options, args)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.FromContext, "from-context", "", "Kubeconfig context of the source (defaults to the current context)")
+	cmd.Flags().StringVar(&options.ToContext, "to-context", "", "Kubeconfig context of the target (defaults to the current context)")
+	cmd.Flags().StringVar(&options.FromNamespace, "from-namespace", "", "Namespace of the source (defaults to the namespace of the source context)")
+	cmd.Flags().StringVar(&options.ToNamespace, "to-namespace", "", "Namespace of the target (defaults to the namespace of the target context)")
+	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Only promote this container (if not specified, promotes all containers)")
+	cmd.RegisterFlagCompletionFunc("container", completeContainers(&options))
+	cmd.Flags().BoolVar(&options.PinDigest, "digest", true, "Promote the digests the source runs instead of its tags")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only show the diff, without updating the target")
+	cmd.Flags().BoolVar(&options.VerifyImage, "verify", false, "Verify that the new images exist in the registry before updating")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+	addConfirmFlags(cmd, &options)
+	addWaitFlags(cmd, &options)
+
+	return cmd
+}
+
+// runPromoteCommand handles the promote command execution
+func runPromoteCommand(cmd *cobra.Command, options *types.Options, args []string) error {
+	// Parse arguments
+	resourceType, resourceName, rest, err := parseResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %v", rest)
+	}
+	options.ResourceType = resourceType
+	options.ResourceName = resourceName
+
+	return executePromoteCommand(cmd, options)
+}
+
+// executePromoteCommand executes the promote command
+func executePromoteCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input before connecting, the namespaces may still be empty
+	v := validator.New(options)
+	if err := v.ValidatePromote(); err != nil {
+		return err
+	}
+
+	// Fail before touching anything when the policies cannot be loaded
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	sourceCluster, err := client.NewCluster(options.FromContext, options.FromNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client for the source: %v", err)
+	}
+	targetCluster, err := client.NewCluster(options.ToContext, options.ToNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client for the target: %v", err)
+	}
+	// An empty context is the current one, compare the names they resolve to
+	if sourceCluster.KubeContext() == targetCluster.KubeContext() && sourceCluster.Namespace == targetCluster.Namespace {
+		return fmt.Errorf("source and target are both namespace %s of the %s", sourceCluster.Namespace, sourceCluster.Name())
+	}
+
+	source := *options
+	source.Namespace = sourceCluster.Namespace
+	source.Clientset = sourceCluster.Clientset
+
+	target := *options
+	target.Namespace = targetCluster.Namespace
+	target.Clientset = targetCluster.Clientset
+	target.Context = targetCluster.KubeContext()
+	target.Out = cmd.OutOrStdout()
+	target.In = bufio.NewReader(cmd.InOrStdin())
+	target.ErrOut = cmd.ErrOrStderr()
+
+	promoter := promote.New(&source, &target, cfg)
+	plan, err := promoter.Plan(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Promoting %s/%s from %s (namespace %s) to %s (namespace %s)\n",
+		options.ResourceType, options.ResourceName,
+		sourceCluster.Name(), sourceCluster.Namespace, targetCluster.Name(), targetCluster.Namespace)
+	printPromotePlan(out, plan)
+
+	changed := false
+	for _, container := range plan.Containers {
+		changed = changed || container.Changed()
+	}
+	if !changed {
+		fmt.Fprintln(out, "Nothing to promote, the target already runs the source images")
+		return nil
+	}
+	if options.DryRun {
+		return nil
+	}
+
+	// The target decides where the update goes, so make it visible
+	printTarget(cmd.ErrOrStderr(), &target, cfg)
+
+	_, err = promoter.Apply(cmd.Context(), plan)
+	return err
+}
+
+// printPromotePlan prints the image diff of every promoted container
+func printPromotePlan(out io.Writer, plan *promote.Plan) {
+	for _, container := range plan.Containers {
+		if !container.Changed() {
+			fmt.Fprintf(out, "  container %s: unchanged (%s)\n", container.Name, container.Current)
+			continue
+		}
+		fmt.Fprintf(out, "  container %s:\n", container.Name)
+		fmt.Fprintf(out, "    - %s\n", container.Current)
+		fmt.Fprintf(out, "    + %s\n", container.Image)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+}
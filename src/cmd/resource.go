@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseResourceArgs parses the resource from either "TYPE NAME" or "TYPE/NAME" arguments.
+// The remaining arguments are returned unchanged.
+func parseResourceArgs(args []string) (string, string, []string, error) {
+	if len(args) == 0 {
+		return "", "", nil, fmt.Errorf("resource type and name are required")
+	}
+
+	if resourceType, name, found := strings.Cut(args[0], "/"); found {
+		return resourceType, name, args[1:], nil
+	}
+
+	if len(args) < 2 {
+		return "", "", nil, fmt.Errorf("resource name is required, use RESOURCE_TYPE NAME or RESOURCE_TYPE/NAME")
+	}
+	return args[0], args[1], args[2:], nil
+}
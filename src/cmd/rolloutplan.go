@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/plan"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createRolloutPlanCommand creates the 'rollout-plan' subcommand
+func createRolloutPlanCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "rollout-plan -f PLAN",
+		Short: "Roll out an image stage by stage across deployments and clusters",
+		Long: `Roll out an image stage by stage, e.g. a canary first, then some regions, then the rest.
+
+The targets of a stage are updated at the same time and their rollouts are
+waited for. Once a stage succeeded and stayed healthy for its soak time the
+next stage starts. A failed stage halts the plan, or rolls its targets back
+to their previous images with onFailure: rollback.
+
+Progress is saved to a state file next to the plan (plan.state.yaml), so an
+interrupted or failed plan continues with the first unfinished stage when it
+is run again.
+
+  apiVersion: kubectl-image/v1
+  kind: RolloutPlan
+  image: ghcr.io/acme/api:v1.5.0
+  wait: {for: available, timeout: 10m}
+  onFailure: rollback
+  stages:
+    - name: canary
+      targets:
+        - {context: prod-eu-1, namespace: shop, resource: deployment/api-canary}
+      soak: 30m
+    - name: regions
+      targets:
+        - {context: prod-eu-1, namespace: shop, resource: deployment/api}
+        - {context: prod-us-1, namespace: shop, resource: deployment/api}
+      soak: 1h
+    - name: rest
+      onFailure: halt
+      targets:
+        - {context: prod-ap-1, namespace: shop, resource: api, container: app}
+
+Examples:
+  # Preview the changes of every stage
+  kubectl image rollout-plan -f plan.yaml --dry-run
+
+  # Run the plan, or resume it after an interruption
+  kubectl image rollout-plan -f plan.yaml --yes
+
+  # Ignore the saved progress and start with the first stage again
+  kubectl image rollout-plan -f plan.yaml --restart
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeRolloutPlanCommand(cmd, &options)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Filename, "filename", "f", "", "Rollout plan to run")
+	cmd.Flags().StringVar(&options.StateFile, "state", "", "File recording the progress of the plan (defaults to PLAN.state.yaml)")
+	cmd.Flags().BoolVar(&options.Restart, "restart", false, "Ignore the recorded progress and start with the first stage")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only print the image every target would get, without updating or recording anything")
+	cmd.Flags().BoolVarP(&options.Yes, "yes", "y", false, "Update protected contexts and namespaces without asking, targets are updated in parallel so they cannot be prompted for")
+	cmd.Flags().BoolVar(&options.NoEmoji, "no-emoji", false, "Use plain text markers instead of emoji in progress output")
+
+	return cmd
+}
+
+// executeRolloutPlanCommand executes the rollout-plan command
+func executeRolloutPlanCommand(cmd *cobra.Command, options *types.Options) error {
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateRolloutPlan(); err != nil {
+		return err
+	}
+
+	// Fail before touching anything when the policies cannot be loaded
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	rolloutPlan, err := plan.Load(options.Filename)
+	if err != nil {
+		return err
+	}
+
+	statePath := options.StateFile
+	if statePath == "" {
+		statePath = plan.StatePath(options.Filename)
+	}
+	state := plan.NewState(statePath, rolloutPlan)
+	if !options.Restart {
+		if state, err = plan.LoadState(statePath, rolloutPlan); err != nil {
+			return err
+		}
+	}
+	if !options.DryRun {
+		fmt.Fprintf(cmd.ErrOrStderr(), ">>> Recording progress in %s\n", statePath)
+	}
+
+	return plan.NewRunner(rolloutPlan, state, options, cfg, cmd.OutOrStdout()).Run(cmd.Context())
+}
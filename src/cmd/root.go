@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/spf13/cobra"
 )
 
@@ -14,22 +19,35 @@ func CreateImageCommand() *cobra.Command {
 This is a kubectl plugin. Install it and use as:
   kubectl image set deployment myapp nginx:1.20
   kubectl image get deployment myapp
+  kubectl image status deployment myapp --wait
 `,
 	}
 
 	// Add subcommands
 	cmd.AddCommand(createSetCommand())
+	cmd.AddCommand(createBumpCommand())
+	cmd.AddCommand(createPromoteCommand())
 	cmd.AddCommand(createGetCommand())
+	cmd.AddCommand(createListCommand())
+	cmd.AddCommand(createStatusCommand())
+	cmd.AddCommand(createTagsCommand())
+	cmd.AddCommand(createOutdatedCommand())
+	cmd.AddCommand(createDiffCommand())
+	cmd.AddCommand(createExportCommand())
+	cmd.AddCommand(createApplyCommand())
+	cmd.AddCommand(createRolloutPlanCommand())
+	cmd.AddCommand(createVerifyCommand())
+	cmd.AddCommand(createPolicyCommand())
 	cmd.AddCommand(createVersionCommand())
 
 	return cmd
 }
 
 // Execute runs the command.
+// The context passed to subcommands is cancelled on SIGINT or SIGTERM.
 func Execute() error {
-	rootCmd := CreateImageCommand()
-	rootCmd.CompletionOptions = cobra.CompletionOptions{
-		DisableDefaultCmd: true,
-	}
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return CreateImageCommand().ExecuteContext(ctx)
 }
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"slices"
 
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
 	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/helm"
+	"github.com/reedchan7/kubectl-image/src/pkg/kustomize"
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
 	"github.com/reedchan7/kubectl-image/src/pkg/setter"
 	"github.com/reedchan7/kubectl-image/src/pkg/types"
 	"github.com/reedchan7/kubectl-image/src/pkg/validator"
@@ -15,7 +25,7 @@ func createSetCommand() *cobra.Command {
 	var options types.Options
 
 	cmd := &cobra.Command{
-		Use:   "set RESOURCE_TYPE NAME [IMAGE]",
+		Use:   "set (TYPE NAME | TYPE/NAME | --kustomize DIR IMAGE_NAME | --helm-values FILE --path PATH) [IMAGE]",
 		Short: "Set the image of a Kubernetes resource",
 		Long: `Set the image of a Kubernetes resource such as deployment.
 
@@ -33,50 +43,160 @@ Examples:
   # Set specific container
   kubectl image set deployment myapp --tag v1.0.1 --container app-container
   
+  # Refuse to update when the tag does not exist in the registry
+  kubectl image set deployment myapp --tag v1.0.2 --verify
+
+  # Set the highest semantic version in the registry, or the highest matching a constraint
+  kubectl image set deploy/myapp --latest
+  kubectl image set deploy/myapp --latest='~1.4'
+
+  # Date based tags such as 2024.05.01-abc123, only print the tag that would be set
+  kubectl image set deploy/myapp --latest --tag-pattern '^(\d{4}\.\d{2}\.\d{2})-[0-9a-f]+$' --dry-run
+
+  # Change the image in local manifests instead of the cluster, keeping comments and formatting
+  kubectl image set deploy/myapp --tag v1.0.3 -f k8s/
+  cat deploy.yaml | kubectl image set deploy/myapp --tag v1.0.3 -f - > deploy.new.yaml
+
+  # Pin the image of a kustomize overlay through its images entry
+  kubectl image set --kustomize overlays/prod myapp --tag v1.0.3
+
+  # Set the image of a Helm values file, repository/tag/digest/registry layouts are detected
+  kubectl image set --helm-values values-prod.yaml --path app.image --tag v1.0.3
+
+  # Check the edit against the policies of the namespace the values are deployed to
+  kubectl image set --helm-values values-prod.yaml --path app.image --tag v1.0.3 -n payments
+
+  # Show the change and ask before updating, or skip the prompt of protected namespaces
+  kubectl image set deployment myapp --tag v1.0.3 --confirm
+  kubectl image set deployment myapp --tag v1.0.3 --yes
+
+  # Set the image in several clusters at once, or region by region stopping at the first failure
+  kubectl image set deployment myapp --tag v1.0.3 --contexts eu-west,us-east --yes
+  kubectl image set deployment myapp --tag v1.0.3 --all-contexts='prod-*' --sequential
+  kubectl image set deployment myapp --tag v1.0.3 --contexts us-canary,eu-1,asia-1 --sequential
+
   # Set image and wait for rollout to complete
   kubectl image set deployment myapp nginx:1.21 --wait
+
+  # Return as soon as the new pods are ready (useful in CI)
+  kubectl image set deployment myapp --tag v1.0.3 --wait --wait-for ready
+
+  # Insist on full termination of the old pods, for up to 15 minutes
+  kubectl image set deployment myapp --tag v1.0.3 --wait --cleanup-grace 0 --timeout 15m
+
+  # Emit newline-delimited JSON progress events for dashboards
+  kubectl image set deployment myapp --tag v1.0.3 --wait --progress json
 `,
-		Args: cobra.MinimumNArgs(2),
+		Args:              cobra.MaximumNArgs(4),
+		ValidArgsFunction: completeResourceArgs(&options),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runSetCommand(&options, args)
+			return runSetCommand(cmd, &options, args)
 		},
 	}
 
 	// Add flags
 	cmd.Flags().StringVarP(&options.Tag, "tag", "t", "", "Image tag to set")
+	cmd.RegisterFlagCompletionFunc("tag", completeTags(&options))
 	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Container name to update (if not specified, updates first container)")
-	cmd.Flags().BoolVarP(&options.Wait, "wait", "w", false, "Wait for the rollout to complete before returning")
+	cmd.RegisterFlagCompletionFunc("container", completeContainers(&options))
+	cmd.Flags().BoolVar(&options.VerifyImage, "verify", false, "Verify that the new image exists in the registry before updating")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+	cmd.Flags().StringVar(&options.LatestConstraint, "latest", "", "Set the highest registry tag matching an optional semver constraint, e.g. --latest or --latest='~1.4'")
+	cmd.Flags().Lookup("latest").NoOptDefVal = "*"
+	cmd.Flags().StringVar(&options.TagPattern, "tag-pattern", "", "Regular expression for --latest whose first capture group is the version of a tag, for non-semver tags")
+	cmd.Flags().BoolVar(&options.IncludePreRelease, "include-prerelease", false, "Let --latest choose pre-release versions such as 1.5.0-rc.1")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only print the tag that would be set, without updating the resource")
+	cmd.Flags().StringSliceVarP(&options.Filenames, "filename", "f", nil, "Manifest files or directories to edit instead of the cluster, - for stdin")
+	cmd.Flags().BoolVarP(&options.Recursive, "recursive", "R", false, "Read the directories given with --filename recursively")
+	cmd.Flags().StringVarP(&options.Kustomize, "kustomize", "k", "", "Kustomization directory whose images entry is edited instead of the cluster")
+	cmd.Flags().StringVar(&options.HelmValues, "helm-values", "", "Helm values file whose image at --path is edited instead of the cluster")
+	cmd.Flags().StringVar(&options.ValuesPath, "path", "", "Dotted path of the image in the --helm-values file, e.g. app.image")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of the resource (defaults to the current kubectl context namespace), for --filename, --kustomize and --helm-values the namespace policies see (manifests with metadata.namespace keep theirs)")
+	addConfirmFlags(cmd, &options)
+	addContextsFlags(cmd, &options)
+	cmd.Flags().BoolVar(&options.Sequential, "sequential", false, "Set the image in one context after the other, waiting for each rollout and stopping at the first failure")
+	addWaitFlags(cmd, &options)
 
 	return cmd
 }
 
 // runSetCommand handles the set command execution
-func runSetCommand(options *types.Options, args []string) error {
+func runSetCommand(cmd *cobra.Command, options *types.Options, args []string) error {
 	// Parse arguments
-	options.ResourceType = args[0]
-	options.ResourceName = args[1]
-	if len(args) >= 3 {
-		options.Image = args[2]
+	var rest []string
+	switch {
+	case options.HelmValues != "":
+		// --path selects the image, so only the new image may be given
+		rest = args
+	case options.Kustomize != "":
+		// Kustomize images entries are named after the image, not after a resource
+		if len(args) == 0 {
+			return fmt.Errorf("image name is required, e.g. --kustomize overlays/prod myapp")
+		}
+		options.ImageName, rest = args[0], args[1:]
+	default:
+		resourceType, resourceName, remaining, err := parseResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		options.ResourceType = resourceType
+		options.ResourceName = resourceName
+		rest = remaining
 	}
 
-	return executeSetCommand(options)
+	if cmd.Flags().Changed("latest") {
+		options.Latest = true
+	}
+
+	if len(rest) > 1 {
+		return fmt.Errorf("unexpected arguments: %v", rest[1:])
+	}
+	if len(rest) == 1 {
+		// The flag value is optional, so "--latest '~1.4'" leaves the constraint as the image argument
+		if _, err := reference.Parse(rest[0]); options.Latest && err != nil {
+			return fmt.Errorf("%q is not an image, pass the version constraint as --latest=CONSTRAINT", rest[0])
+		}
+		options.Image = rest[0]
+	}
+
+	return executeSetCommand(cmd, options)
 }
 
 // executeSetCommand executes the image set command
-func executeSetCommand(options *types.Options) error {
-	// Get current namespace from kubectl context
-	if ns, err := client.GetCurrentNamespace(); err == nil {
-		options.Namespace = ns
-	} else {
-		options.Namespace = "default"
+func executeSetCommand(cmd *cobra.Command, options *types.Options) error {
+	// Fail before touching anything when the policies cannot be loaded
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if len(options.Filenames) > 0 {
+		return executeSetManifestCommand(cmd, options, cfg)
+	}
+	if options.Kustomize != "" {
+		return executeSetKustomizeCommand(cmd, options, cfg)
+	}
+	if options.HelmValues != "" {
+		return executeSetHelmValuesCommand(cmd, options, cfg)
+	}
+	if multipleContexts(options) {
+		return executeSetContextsCommand(cmd, options, cfg)
 	}
 
-	// Create Kubernetes client
-	clientset, err := client.NewClient()
+	// Connect to the current kubectl context, in its namespace unless given explicitly.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster("", options.Namespace)
 	if err != nil {
 		return fmt.Errorf("failed to create Kubernetes client: %v", err)
 	}
-	options.Clientset = clientset
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	options.In = bufio.NewReader(cmd.InOrStdin())
+	options.ErrOut = cmd.ErrOrStderr()
+	if !options.DryRun {
+		options.Out = cmd.OutOrStdout()
+	}
 
 	// Validate input
 	v := validator.New(options)
@@ -84,7 +204,135 @@ func executeSetCommand(options *types.Options) error {
 		return err
 	}
 
+	// The kubeconfig decides where the update goes, so make it visible
+	printTarget(cmd.ErrOrStderr(), options, cfg)
+
+	// Report every missing permission before changing anything
+	if err := access.Check(cmd.Context(), options.Clientset, options.Namespace, access.Set(options, cfg)); err != nil {
+		return err
+	}
+
 	// Set image
-	s := setter.New(options)
-	return s.Set()
+	s := setter.New(options, cfg)
+	result, err := s.Set(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), getter.ExtractTag(result.NewImage))
+	}
+	return nil
+}
+
+// executeSetManifestCommand sets the image in local manifest files, without connecting to the cluster
+func executeSetManifestCommand(cmd *cobra.Command, options *types.Options, cfg *config.Config) error {
+	// Manifests read from stdin are written to stdout, so progress goes to stderr
+	if !options.DryRun {
+		options.Out = cmd.OutOrStdout()
+		if slices.Contains(options.Filenames, "-") {
+			options.Out = cmd.ErrOrStderr()
+		}
+	}
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateSet(); err != nil {
+		return err
+	}
+
+	files, err := manifest.Load(options.Filenames, options.Recursive, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	s := setter.New(options, cfg)
+	result, err := s.SetManifest(cmd.Context(), files)
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), getter.ExtractTag(result.NewImage))
+		return nil
+	}
+	for _, file := range files {
+		if file.Path == result.Source {
+			return file.Save(cmd.OutOrStdout())
+		}
+	}
+	return nil
+}
+
+// executeSetKustomizeCommand sets the images entry of a kustomization, without connecting to the cluster
+func executeSetKustomizeCommand(cmd *cobra.Command, options *types.Options, cfg *config.Config) error {
+	if !options.DryRun {
+		options.Out = cmd.OutOrStdout()
+	}
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateSet(); err != nil {
+		return err
+	}
+
+	k, err := kustomize.Load(options.Kustomize)
+	if err != nil {
+		return err
+	}
+
+	s := setter.New(options, cfg)
+	result, err := s.SetKustomization(cmd.Context(), k)
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), getter.ExtractTag(result.NewImage))
+		return nil
+	}
+	return k.Save()
+}
+
+// executeSetHelmValuesCommand sets the image of a Helm values file, without connecting to the cluster
+func executeSetHelmValuesCommand(cmd *cobra.Command, options *types.Options, cfg *config.Config) error {
+	if !options.DryRun {
+		options.Out = cmd.OutOrStdout()
+	}
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateSet(); err != nil {
+		return err
+	}
+
+	values, err := helm.Load(options.HelmValues)
+	if err != nil {
+		return err
+	}
+
+	s := setter.New(options, cfg)
+	result, err := s.SetHelmValues(cmd.Context(), values)
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), getter.ExtractTag(result.NewImage))
+		return nil
+	}
+	return values.Save()
+}
+
+// printTarget prints the cluster and namespace about to be changed, so a wrong kubeconfig context stands out
+func printTarget(out io.Writer, options *types.Options, cfg *config.Config) {
+	context := options.Context
+	if context == "" {
+		context = "(none)"
+	}
+	line := fmt.Sprintf(">>> Target: context %s, namespace %s", context, options.Namespace)
+	if cfg.Protected.Matches(options.Context, options.Namespace) {
+		line += " [PROTECTED]"
+	}
+	fmt.Fprintln(out, line)
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createStatusCommand creates the 'status' subcommand
+func createStatusCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "status (TYPE NAME | TYPE/NAME)",
+		Short: "Show the rollout status of a Kubernetes resource",
+		Long: `Show the rollout status of a Kubernetes resource such as deployment.
+
+Shows the current and target images, per-ReplicaSet pod counts and stuck pods.
+Use --wait to attach to an in-progress rollout and block until it is done.
+
+Examples:
+  # Show the rollout status of a deployment
+  kubectl image status deployment myapp
+
+  # Attach to a rollout started elsewhere and wait for it to finish
+  kubectl image status deploy/myapp -n prod --wait
+`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeResourceArgs(&options),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatusCommand(cmd, &options, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of the resource (defaults to the current kubectl context namespace)")
+	cmd.Flags().StringVar(&options.Context, "context", "", "Kubeconfig context to use (defaults to the current context)")
+	addWaitFlags(cmd, &options)
+
+	return cmd
+}
+
+// runStatusCommand handles the status command execution
+func runStatusCommand(cmd *cobra.Command, options *types.Options, args []string) error {
+	// Parse arguments
+	resourceType, resourceName, rest, err := parseResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %v", rest)
+	}
+	options.ResourceType = resourceType
+	options.ResourceName = resourceName
+
+	return executeStatusCommand(cmd, options)
+}
+
+// executeStatusCommand executes the rollout status command
+func executeStatusCommand(cmd *cobra.Command, options *types.Options) error {
+	// Connect to --context or the current kubectl context, in its namespace unless given explicitly.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster(options.Context, options.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	options.Out = cmd.OutOrStdout()
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateStatus(); err != nil {
+		return err
+	}
+
+	// A missing permission would otherwise only show up late while waiting
+	if err := access.Check(cmd.Context(), options.Clientset, options.Namespace, access.Status(options)); err != nil {
+		return err
+	}
+
+	w := rollout.New(options)
+	status, err := w.Status(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(options.Progress) == string(types.ProgressJSON) {
+		if err := json.NewEncoder(options.Out).Encode(status); err != nil {
+			return err
+		}
+	} else {
+		printStatus(options.Out, status)
+	}
+
+	if !options.Wait || status.Complete {
+		return nil
+	}
+
+	_, err = w.Wait(cmd.Context())
+	return err
+}
+
+// printStatus prints a human readable rollout status
+func printStatus(out io.Writer, status *rollout.Status) {
+	state := "in progress"
+	if status.Complete {
+		state = "complete"
+	}
+	fmt.Fprintf(out, "Deployment %s/%s rollout %s (revision %s)\n", status.Namespace, status.Deployment, state, status.Revision)
+	fmt.Fprintf(out, "Pods: %d desired, %d updated, %d ready, %d available\n",
+		status.Desired, status.Updated, status.Ready, status.Available)
+	if status.Message != "" {
+		fmt.Fprintf(out, "Message: %s\n", status.Message)
+	}
+
+	fmt.Fprintln(out, "\nTarget images:")
+	for _, image := range status.TargetImages {
+		fmt.Fprintf(out, "  %s: %s\n", image.Container, image.Image)
+	}
+	if len(status.CurrentImages) > 0 {
+		fmt.Fprintln(out, "Current images:")
+		for _, image := range status.CurrentImages {
+			fmt.Fprintf(out, "  %s: %s\n", image.Container, image.Image)
+		}
+	}
+
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPLICASET\tREVISION\tDESIRED\tCURRENT\tREADY\tAVAILABLE\tIMAGES")
+	for _, rs := range status.ReplicaSets {
+		name := rs.Name
+		if rs.New {
+			name += " (new)"
+		}
+		images := make([]string, 0, len(rs.Images))
+		for _, image := range rs.Images {
+			images = append(images, image.Image)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+			name, rs.Revision, rs.Desired, rs.Current, rs.Ready, rs.Available, strings.Join(images, ","))
+	}
+	tw.Flush()
+
+	if len(status.StuckPods) > 0 {
+		fmt.Fprintln(out, "\nPods not ready:")
+		for _, pod := range status.StuckPods {
+			fmt.Fprintf(out, "  %s: %s\n", pod.Name, pod.Status)
+			for _, container := range pod.Containers {
+				fmt.Fprintf(out, "    Container %s %s: %s - %s\n", container.Name, container.State, container.Reason, container.Message)
+			}
+		}
+	}
+}
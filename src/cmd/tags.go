@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/tags"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+// createTagsCommand creates the 'tags' subcommand
+func createTagsCommand() *cobra.Command {
+	var options types.Options
+
+	cmd := &cobra.Command{
+		Use:   "tags (TYPE NAME | TYPE/NAME)",
+		Short: "List the registry tags of a resource's image",
+		Long: `List the tags that exist in the registry for the image a resource runs.
+
+The repository is taken from the current image of the resource and the tags are
+read with the OCI distribution API.
+
+Examples:
+  # List the tags of a deployment's image, newest version first
+  kubectl image tags deploy/myapp
+
+  # List the tags of a specific container's image
+  kubectl image tags deployment myapp --container sidecar
+
+  # Only release candidates, most recently built first
+  kubectl image tags deploy/myapp --filter '-rc\.[0-9]+$' --sort time
+`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeResourceArgs(&options),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTagsCommand(cmd, &options, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace of the resource (defaults to the current kubectl context namespace)")
+	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Container whose image is used (if not specified, uses first container)")
+	cmd.RegisterFlagCompletionFunc("container", completeContainers(&options))
+	cmd.Flags().StringVar(&options.TagFilter, "filter", "", "Only list tags matching this regular expression")
+	cmd.Flags().StringVar(&options.TagSort, "sort", string(types.TagSortSemver), "Sort order: semver, lexical or time (image creation time, where available)")
+	cmd.Flags().BoolVar(&options.PlainHTTP, "plain-http", false, "Use plain HTTP to talk to the registry (always used for localhost)")
+
+	return cmd
+}
+
+// runTagsCommand handles the tags command execution
+func runTagsCommand(cmd *cobra.Command, options *types.Options, args []string) error {
+	// Parse arguments
+	resourceType, resourceName, rest, err := parseResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %v", rest)
+	}
+	options.ResourceType = resourceType
+	options.ResourceName = resourceName
+
+	return executeTagsCommand(cmd, options)
+}
+
+// executeTagsCommand executes the tags command
+func executeTagsCommand(cmd *cobra.Command, options *types.Options) error {
+	// Connect to the current kubectl context, in its namespace unless given explicitly.
+	// Client, namespace and context name come from the same kubeconfig.
+	cluster, err := client.NewCluster("", options.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	options.Namespace = cluster.Namespace
+	options.Clientset = cluster.Clientset
+	options.Context = cluster.KubeContext()
+	options.ErrOut = cmd.ErrOrStderr()
+
+	// Validate input
+	v := validator.New(options)
+	if err := v.ValidateTags(); err != nil {
+		return err
+	}
+
+	result, err := tags.New(options).List(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range result.Tags {
+		fmt.Fprintln(cmd.OutOrStdout(), tag.Name)
+	}
+	return nil
+}
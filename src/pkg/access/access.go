@@ -0,0 +1,245 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a verb on a namespaced resource that a command needs
+type Permission struct {
+	Verb     string
+	Group    string
+	Resource string
+	// Name limits the permission to one object, empty for all objects
+	Name string
+	// ClusterScoped resources such as nodes are checked outside the namespace and granted by a ClusterRole
+	ClusterScoped bool
+}
+
+// String returns the permission the way kubectl auth can-i takes it, e.g. "update deployments.apps/myapp"
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Name != "" {
+		resource += "/" + p.Name
+	}
+	return p.Verb + " " + resource
+}
+
+// Get returns the permissions the get command needs
+func Get(options *types.Options) []Permission {
+	if types.ValidResourceTypes[strings.ToLower(options.ResourceType)] == types.ResourceTypePod {
+		return []Permission{{Verb: "get", Resource: "pods", Name: options.ResourceName}}
+	}
+	return []Permission{{Verb: "get", Group: "apps", Resource: "deployments", Name: options.ResourceName}}
+}
+
+// List returns the permissions the list command needs to list deployments and the digests of their pods
+func List() []Permission {
+	return []Permission{
+		{Verb: "list", Group: "apps", Resource: "deployments"},
+		{Verb: "list", Resource: "pods"},
+	}
+}
+
+// Set returns the permissions the set and bump commands need to update a deployment
+// with the enabled options and the policies of the configuration
+func Set(options *types.Options, cfg *config.Config) []Permission {
+	permissions := []Permission{{Verb: "get", Group: "apps", Resource: "deployments", Name: options.ResourceName}}
+	if !options.DryRun {
+		permissions = append(permissions, Permission{Verb: "update", Group: "apps", Resource: "deployments", Name: options.ResourceName})
+	}
+	// Policy rules may read the labels of the namespace
+	if cfg != nil && policy.NeedsNamespaceLabels(cfg.Policies, policy.Target{Context: options.Context, Namespace: options.Namespace}) {
+		permissions = append(permissions, Permission{Verb: "get", Resource: "namespaces", Name: options.Namespace})
+	}
+	// The registry is accessed with the pull secrets of the pods
+	if options.Latest || options.VerifyImage {
+		permissions = append(permissions,
+			Permission{Verb: "get", Resource: "secrets"},
+			Permission{Verb: "get", Resource: "serviceaccounts"},
+		)
+	}
+	// --verify compares the image platforms with the node architectures
+	if options.VerifyImage {
+		permissions = append(permissions, Permission{Verb: "list", Resource: "nodes", ClusterScoped: true})
+	}
+	if options.Wait {
+		permissions = append(permissions, rollout()...)
+	}
+	return permissions
+}
+
+// Status returns the permissions the status command needs
+func Status(options *types.Options) []Permission {
+	permissions := []Permission{
+		{Verb: "get", Group: "apps", Resource: "deployments", Name: options.ResourceName},
+		{Verb: "list", Group: "apps", Resource: "replicasets"},
+		{Verb: "list", Resource: "pods"},
+	}
+	if options.Wait {
+		permissions = append(permissions, rollout()...)
+	}
+	return permissions
+}
+
+// rollout returns the permissions needed to follow a rollout
+func rollout() []Permission {
+	return []Permission{
+		{Verb: "list", Group: "apps", Resource: "replicasets"},
+		{Verb: "list", Resource: "pods"},
+		{Verb: "list", Resource: "events"},
+	}
+}
+
+// Check asks the API server whether the current user has the permissions in the namespace,
+// or in the cluster for cluster-scoped ones, one SelfSubjectAccessReview each, and returns
+// a *types.MissingPermissionsError listing all missing ones. Nothing is reported when the reviews themselves fail, the command then runs
+// into the actual error instead.
+func Check(ctx context.Context, clientset kubernetes.Interface, namespace string, permissions []Permission) error {
+	permissions = dedupe(permissions)
+	allowed := make([]bool, len(permissions))
+	errs := make([]error, len(permissions))
+
+	var wg sync.WaitGroup
+	for i, permission := range permissions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reviewNamespace := namespace
+			if permission.ClusterScoped {
+				reviewNamespace = ""
+			}
+			review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: reviewNamespace,
+						Verb:      permission.Verb,
+						Group:     permission.Group,
+						Resource:  permission.Resource,
+						Name:      permission.Name,
+					},
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			allowed[i] = review.Status.Allowed
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var missing []Permission
+	for i, permission := range permissions {
+		if errs[i] == nil && !allowed[i] {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(missing))
+	var namespaced, clusterScoped []Permission
+	for _, permission := range missing {
+		names = append(names, permission.String())
+		if permission.ClusterScoped {
+			clusterScoped = append(clusterScoped, permission)
+		} else {
+			namespaced = append(namespaced, permission)
+		}
+	}
+	err := &types.MissingPermissionsError{
+		Namespace:   namespace,
+		Permissions: names,
+	}
+	if len(namespaced) > 0 {
+		err.Rules = Rules(namespaced)
+	}
+	if len(clusterScoped) > 0 {
+		err.ClusterRules = Rules(clusterScoped)
+	}
+	return err
+}
+
+// Rules returns the Role rules granting the permissions, one rule per resource
+func Rules(permissions []Permission) string {
+	type key struct{ group, resource string }
+	var order []key
+	verbs := make(map[key][]string)
+	names := make(map[key][]string)
+	for _, permission := range permissions {
+		k := key{permission.Group, permission.Resource}
+		if _, exists := verbs[k]; !exists {
+			order = append(order, k)
+		}
+		if !slices.Contains(verbs[k], permission.Verb) {
+			verbs[k] = append(verbs[k], permission.Verb)
+		}
+		if permission.Name != "" && !slices.Contains(names[k], permission.Name) {
+			names[k] = append(names[k], permission.Name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("rules:\n")
+	for _, k := range order {
+		fmt.Fprintf(&b, "- apiGroups: [%q]\n", k.group)
+		fmt.Fprintf(&b, "  resources: [%q]\n", k.resource)
+		// Resource names only restrict a rule when every permission of it is for a named object
+		if len(names[k]) > 0 && allNamed(permissions, k.group, k.resource) {
+			fmt.Fprintf(&b, "  resourceNames: [%s]\n", quoteList(names[k]))
+		}
+		fmt.Fprintf(&b, "  verbs: [%s]\n", quoteList(verbs[k]))
+	}
+	return b.String()
+}
+
+// allNamed reports whether every permission on a resource names its object
+func allNamed(permissions []Permission, group, resource string) bool {
+	for _, permission := range permissions {
+		if permission.Group == group && permission.Resource == resource && permission.Name == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupe removes repeated permissions, keeping the first of each
+func dedupe(permissions []Permission) []Permission {
+	seen := make(map[Permission]bool, len(permissions))
+	result := make([]Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	return result
+}
+
+// quoteList formats values as a YAML flow sequence body, e.g. "get", "update"
+func quoteList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	return strings.Join(quoted, ", ")
+}
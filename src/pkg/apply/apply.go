@@ -0,0 +1,145 @@
+package apply
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/lockfile"
+	"github.com/reedchan7/kubectl-image/src/pkg/setter"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// defaultParallelism is the number of deployments updated at the same time
+const defaultParallelism = 4
+
+// Status is the outcome for a single deployment
+type Status string
+
+const (
+	StatusUnchanged Status = "unchanged"
+	// StatusPending means the deployment would be updated, it is the result of a dry run
+	StatusPending Status = "pending"
+	StatusUpdated Status = "updated"
+	StatusFailed  Status = "failed"
+)
+
+// Applier sets the images of a lock file on a cluster
+type Applier struct {
+	options *types.Options
+	cfg     *config.Config
+	file    *lockfile.File
+}
+
+// Item is the result for a single deployment of the lock file
+type Item struct {
+	Namespace  string          `json:"namespace"`
+	Deployment string          `json:"deployment"`
+	Status     Status          `json:"status"`
+	Changes    []setter.Change `json:"changes,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	// Seconds is the time taken to update and, with --wait, roll out the deployment
+	Seconds float64 `json:"seconds,omitempty"`
+
+	images map[string]string
+}
+
+// New creates an Applier for a lock file, whose updates are checked against the configuration
+func New(options *types.Options, cfg *config.Config, file *lockfile.File) *Applier {
+	return &Applier{
+		options: options,
+		cfg:     cfg,
+		file:    file,
+	}
+}
+
+// Plan compares the lock file with the cluster without changing anything.
+// Workloads without namespace in the lock file are looked up in the options namespace.
+func (a *Applier) Plan(ctx context.Context) []Item {
+	items := make([]Item, 0, len(a.file.Workloads))
+	for _, workload := range a.file.Workloads {
+		item := Item{
+			Namespace:  workload.Namespace,
+			Deployment: workload.Name,
+			images:     make(map[string]string, len(workload.Containers)),
+		}
+		if item.Namespace == "" {
+			item.Namespace = a.options.Namespace
+		}
+		for _, container := range workload.Containers {
+			item.images[container.Name] = container.Image
+		}
+
+		result, err := setter.New(a.setterOptions(&item, true), a.cfg).Set(ctx)
+		switch {
+		case err != nil:
+			item.Status = StatusFailed
+			item.Error = err.Error()
+		case len(result.Changes) == 0:
+			item.Status = StatusUnchanged
+		default:
+			item.Status = StatusPending
+			item.Changes = result.Changes
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Apply updates the deployments with pending changes, several at a time, and records the outcome of each
+func (a *Applier) Apply(ctx context.Context, items []Item) []Item {
+	parallelism := a.options.Concurrency
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i := range items {
+		if items[i].Status != StatusPending {
+			continue
+		}
+		wg.Add(1)
+		go func(item *Item) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			result, err := setter.New(a.setterOptions(item, false), a.cfg).Set(ctx)
+			item.Seconds = time.Since(start).Round(time.Millisecond).Seconds()
+			if err != nil {
+				item.Status = StatusFailed
+				item.Error = err.Error()
+				if result != nil {
+					// The images were set, only the rollout did not finish
+					item.Changes = result.Changes
+				}
+				return
+			}
+			item.Status = StatusUpdated
+			item.Changes = result.Changes
+		}(&items[i])
+	}
+	wg.Wait()
+
+	return items
+}
+
+// setterOptions returns the options to set the locked images of a deployment
+func (a *Applier) setterOptions(item *Item, dryRun bool) *types.Options {
+	options := *a.options
+	options.ResourceType = string(types.ResourceTypeDeployment)
+	options.ResourceName = item.Deployment
+	options.Namespace = item.Namespace
+	options.ContainerName = ""
+	options.ContainerImages = item.images
+	options.DryRun = dryRun
+	// Progress of parallel updates would interleave, the report summarizes them instead.
+	// Parallel updates cannot be prompted for either, so protected deployments need Yes.
+	options.Out = nil
+	options.In = nil
+	options.Confirm = false
+	return &options
+}
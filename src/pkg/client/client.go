@@ -1,57 +1,45 @@
 package client
 
 import (
-	"path/filepath"
-
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
-// NewClient creates a new Kubernetes client using the default kubeconfig
+// NewClient creates a new Kubernetes client for the current kubeconfig context
 func NewClient() (kubernetes.Interface, error) {
-	config, err := getKubeConfig()
+	cluster, err := NewCluster("", "")
 	if err != nil {
 		return nil, err
 	}
-
-	return kubernetes.NewForConfig(config)
-}
-
-// getKubeConfig returns the Kubernetes configuration
-func getKubeConfig() (*rest.Config, error) {
-	// Try in-cluster config first
-	if config, err := rest.InClusterConfig(); err == nil {
-		return config, nil
-	}
-
-	// Fall back to kubeconfig file
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
-
-	return clientcmd.BuildConfigFromFlags("", kubeconfig)
+	return cluster.Clientset, nil
 }
 
-// GetCurrentNamespace returns the current namespace from kubeconfig
+// GetCurrentNamespace returns the namespace of the current kubeconfig context, "default" when it has none
 func GetCurrentNamespace() (string, error) {
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
-
-	config, err := clientcmd.LoadFromFile(kubeconfig)
-	if err != nil {
+	namespace, _, err := clientConfig("").Namespace()
+	if err != nil || namespace == "" {
 		return "default", nil // fallback to default namespace
 	}
+	return namespace, nil
+}
 
-	if context, exists := config.Contexts[config.CurrentContext]; exists {
-		if context.Namespace != "" {
-			return context.Namespace, nil
-		}
+// GetCurrentContext returns the current context of the kubeconfig ($KUBECONFIG or ~/.kube/config),
+// empty when there is none, e.g. when running inside the cluster
+func GetCurrentContext() string {
+	config, err := clientConfig("").RawConfig()
+	if err != nil {
+		return ""
 	}
+	return config.CurrentContext
+}
 
-	return "default", nil
+// clientConfig loads the kubeconfig the way kubectl does, from $KUBECONFIG or ~/.kube/config,
+// falling back to the in-cluster configuration when there is none. A context name overrides
+// the current context. The clientset, namespace and context name of a command all come from
+// it, so the context that is checked and printed is always the one being written to.
+func clientConfig(contextName string) clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	)
 }
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/glob"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Cluster is a connection to the cluster of a kubeconfig context
+type Cluster struct {
+	// Context is the kubeconfig context name, empty for the current context
+	Context   string
+	Namespace string
+	Clientset kubernetes.Interface
+
+	// current is the current context of the kubeconfig the cluster was loaded from
+	current string
+}
+
+// NewCluster connects to the cluster of a kubeconfig context.
+// An empty context uses the current context, an empty namespace the context's namespace.
+func NewCluster(contextName, namespace string) (*Cluster, error) {
+	clientConfig := clientConfig(contextName)
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		if contextName == "" {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load kubeconfig context %s: %w", contextName, err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		if contextName == "" {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create client for context %s: %w", contextName, err)
+	}
+
+	if namespace == "" {
+		namespace, _, err = clientConfig.Namespace()
+		if err != nil || namespace == "" {
+			namespace = "default"
+		}
+	}
+
+	cluster := &Cluster{Context: contextName, Namespace: namespace, Clientset: clientset}
+	if raw, err := clientConfig.RawConfig(); err == nil {
+		cluster.current = raw.CurrentContext
+	}
+	return cluster, nil
+}
+
+// Name returns the context name for messages, "current context" when none was given
+func (c *Cluster) Name() string {
+	if c.Context == "" {
+		return "current context"
+	}
+	return c.Context
+}
+
+// KubeContext returns the kubeconfig context of the cluster, resolving the current context
+// from the same kubeconfig the client was created from
+func (c *Cluster) KubeContext() string {
+	if c.Context == "" {
+		return c.current
+	}
+	return c.Context
+}
+
+// Contexts returns the kubeconfig contexts matching the patterns, in the order of the patterns.
+// Patterns are context names or globs, the contexts a glob matches are sorted by name.
+// A pattern matching no context is an error.
+func Contexts(patterns []string) ([]string, error) {
+	config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	var contexts []string
+	for _, pattern := range patterns {
+		var matches []string
+		for name := range config.Contexts {
+			if glob.Match(pattern, name) {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no kubeconfig context matches %s", pattern)
+		}
+		sort.Strings(matches)
+		for _, name := range matches {
+			if !slices.Contains(contexts, name) {
+				contexts = append(contexts, name)
+			}
+		}
+	}
+	return contexts, nil
+}
@@ -0,0 +1,73 @@
+package completion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheTTL is how long completions are reused, long enough for a few tab presses in a row
+// while resources created in the meantime still show up soon
+const cacheTTL = 30 * time.Second
+
+// cache keeps completions in files of the user cache directory, one per lookup
+type cache struct {
+	// dir is empty when there is no cache directory, nothing is cached then
+	dir string
+}
+
+// cacheEntry is the content of a cache file
+type cacheEntry struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+	// Error is set when the lookup failed, so an unreachable cluster or registry is not asked on every tab press
+	Error string `json:"error,omitempty"`
+}
+
+// newCache returns a cache in $XDG_CACHE_HOME/kubectl-image/completion or the platform's equivalent
+func newCache() *cache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return &cache{}
+	}
+	return &cache{dir: filepath.Join(dir, "kubectl-image", "completion")}
+}
+
+// get returns the values or the error cached for the key when they are recent enough, otherwise
+// those of lookup, which are cached. Cache errors only make the lookup happen again.
+func (c *cache) get(key string, lookup func() ([]string, error)) ([]string, error) {
+	if c.dir == "" {
+		return lookup()
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	path := filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < cacheTTL {
+		if data, err := os.ReadFile(path); err == nil {
+			var entry cacheEntry
+			// The key is compared too, in case of a hash collision
+			if json.Unmarshal(data, &entry) == nil && entry.Key == key {
+				if entry.Error != "" {
+					return nil, errors.New(entry.Error)
+				}
+				return entry.Values, nil
+			}
+		}
+	}
+
+	values, err := lookup()
+	entry := cacheEntry{Key: key, Values: values}
+	if err != nil {
+		entry = cacheEntry{Key: key, Error: err.Error()}
+	}
+	if data, err := json.Marshal(entry); err == nil {
+		if os.MkdirAll(c.dir, 0o700) == nil {
+			_ = os.WriteFile(path, data, 0o600)
+		}
+	}
+	return values, err
+}
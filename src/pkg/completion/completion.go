@@ -0,0 +1,189 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/tags"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lookupTimeout bounds cluster and registry requests, so a slow cluster never hangs the shell
+const lookupTimeout = 5 * time.Second
+
+// Completer looks up shell completions in the cluster and the registry.
+// Results are cached briefly, since every tab press starts a new process.
+type Completer struct {
+	options *types.Options
+	cache   *cache
+}
+
+// New creates a Completer for the context and namespace of the options, empty ones
+// use the current context and its namespace
+func New(options *types.Options) *Completer {
+	return &Completer{
+		options: options,
+		cache:   newCache(),
+	}
+}
+
+// ResourceTypes returns the supported resource types and their aliases, sorted
+func ResourceTypes() []string {
+	names := make([]string, 0, len(types.ValidResourceTypes))
+	for name := range types.ValidResourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResourceNames returns the names of the resources of a type in the namespace
+func (c *Completer) ResourceNames(ctx context.Context, resourceType string) ([]string, error) {
+	kind, exists := types.ValidResourceTypes[strings.ToLower(resourceType)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	key := c.key("names", string(kind))
+	return c.cache.get(key, func() ([]string, error) {
+		ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+
+		var names []string
+		switch kind {
+		case types.ResourceTypePod:
+			pods, err := c.options.Clientset.CoreV1().Pods(c.options.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			for _, pod := range pods.Items {
+				names = append(names, pod.Name)
+			}
+		default:
+			deployments, err := c.options.Clientset.AppsV1().Deployments(c.options.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			for _, deployment := range deployments.Items {
+				names = append(names, deployment.Name)
+			}
+		}
+		return names, nil
+	})
+}
+
+// Containers returns the container names of the resource in the options, in spec order
+func (c *Completer) Containers(ctx context.Context) ([]string, error) {
+	kind, exists := types.ValidResourceTypes[strings.ToLower(c.options.ResourceType)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", c.options.ResourceType)
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	key := c.key("containers", string(kind), c.options.ResourceName)
+	return c.cache.get(key, func() ([]string, error) {
+		ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+
+		var containers []corev1.Container
+		switch kind {
+		case types.ResourceTypePod:
+			pod, err := c.options.Clientset.CoreV1().Pods(c.options.Namespace).Get(ctx, c.options.ResourceName, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			containers = pod.Spec.Containers
+		default:
+			deployment, err := c.options.Clientset.AppsV1().Deployments(c.options.Namespace).Get(ctx, c.options.ResourceName, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			containers = deployment.Spec.Template.Spec.Containers
+		}
+
+		names := make([]string, 0, len(containers))
+		for _, container := range containers {
+			names = append(names, container.Name)
+		}
+		return names, nil
+	})
+}
+
+// Tags returns the registry tags of the image the resource in the options runs, newest
+// semantic version first. Only registries with access configured are asked, through docker
+// credentials or pull secrets of the resource, so completion never waits for registries
+// it cannot use. The registry is accessed like the tags command does.
+func (c *Completer) Tags(ctx context.Context) ([]string, error) {
+	if _, exists := types.ValidResourceTypes[strings.ToLower(c.options.ResourceType)]; !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", c.options.ResourceType)
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	key := c.key("tags", c.options.ResourceType, c.options.ResourceName, c.options.ContainerName)
+	return c.cache.get(key, func() ([]string, error) {
+		ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+
+		image, err := getter.New(c.options).Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := reference.Parse(image.Image)
+		if err != nil {
+			return nil, err
+		}
+		if !registry.HasDockerCredentials(ref.Domain) && len(image.ImagePullSecrets) == 0 {
+			return nil, fmt.Errorf("no registry access configured for %s, tags are not completed", ref.Domain)
+		}
+
+		result, err := tags.New(c.options).List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(result.Tags))
+		for _, tag := range result.Tags {
+			names = append(names, tag.Name)
+		}
+		return names, nil
+	})
+}
+
+// connect connects to the cluster of the options' context, once
+func (c *Completer) connect() error {
+	if c.options.Clientset != nil {
+		return nil
+	}
+	cluster, err := client.NewCluster(c.options.Context, c.options.Namespace)
+	if err != nil {
+		return err
+	}
+	c.options.Clientset = cluster.Clientset
+	c.options.Namespace = cluster.Namespace
+	if c.options.Namespace == "" {
+		c.options.Namespace = "default"
+	}
+	c.options.Context = cluster.KubeContext()
+	return nil
+}
+
+// key identifies a lookup in the cache, including the context and namespace it was made in
+func (c *Completer) key(parts ...string) string {
+	return strings.Join(append([]string{c.options.Context, c.options.Namespace}, parts...), "/")
+}
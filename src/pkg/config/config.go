@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/glob"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/util/homedir"
+)
+
+// EnvConfig overrides the location of the configuration file
+const EnvConfig = "KUBECTL_IMAGE_CONFIG"
+
+// Config is the configuration file of kubectl-image
+type Config struct {
+	// Policies restrict the images set commands may write
+	Policies []policy.Policy `yaml:"policies"`
+	// Protected lists where updates must be confirmed
+	Protected Protected `yaml:"protected"`
+}
+
+// Protected holds glob patterns of contexts and namespaces whose deployments are only
+// updated after typing their name. Every namespace of a protected context is protected.
+type Protected struct {
+	Contexts   []string `yaml:"contexts"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// Matches reports whether a context or namespace is protected
+func (p Protected) Matches(context, namespace string) bool {
+	return (context != "" && glob.MatchAny(p.Contexts, context)) ||
+		(namespace != "" && glob.MatchAny(p.Namespaces, namespace))
+}
+
+// Path returns the location of the configuration file: $KUBECTL_IMAGE_CONFIG,
+// $XDG_CONFIG_HOME/kubectl-image/config.yaml or ~/.config/kubectl-image/config.yaml
+func Path() string {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home := homedir.HomeDir()
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "kubectl-image", "config.yaml")
+}
+
+// Load reads the configuration file, a missing file is an empty configuration
+func Load() (*Config, error) {
+	path := Path()
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	config, err := LoadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv(EnvConfig) == "" {
+		return &Config{}, nil
+	}
+	return config, err
+}
+
+// LoadFile reads and checks a configuration file
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	for i := range config.Policies {
+		if err := config.Policies[i].Compile(); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"github.com/reedchan7/kubectl-image/src/pkg/inventory"
+)
+
+// Status describes how a container differs between the two sides
+type Status string
+
+const (
+	// StatusAdded means the container only exists on the right side
+	StatusAdded Status = "added"
+	// StatusRemoved means the container only exists on the left side
+	StatusRemoved Status = "removed"
+	// StatusChanged means the container runs a different image on each side
+	StatusChanged Status = "changed"
+)
+
+// Difference is a container whose image differs between the two sides
+type Difference struct {
+	Status    Status               `json:"status"`
+	Workload  string               `json:"workload"`
+	Container string               `json:"container"`
+	Left      *inventory.Container `json:"left,omitempty"`
+	Right     *inventory.Container `json:"right,omitempty"`
+	// Fields lists what changed: repository, tag and digest
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Compare matches the workloads of both sides by name and returns the containers that differ,
+// ordered by workload and container name as found on the left, then the right side
+func Compare(left, right []inventory.Workload) []Difference {
+	rightByName := make(map[string]inventory.Workload, len(right))
+	for _, workload := range right {
+		rightByName[workload.Name] = workload
+	}
+	leftByName := make(map[string]bool, len(left))
+
+	var differences []Difference
+	for _, l := range left {
+		leftByName[l.Name] = true
+		r, exists := rightByName[l.Name]
+		if !exists {
+			for i := range l.Containers {
+				differences = append(differences, Difference{
+					Status: StatusRemoved, Workload: l.Name, Container: l.Containers[i].Name, Left: &l.Containers[i],
+				})
+			}
+			continue
+		}
+		differences = append(differences, compareWorkload(l, r)...)
+	}
+
+	for _, r := range right {
+		if leftByName[r.Name] {
+			continue
+		}
+		for i := range r.Containers {
+			differences = append(differences, Difference{
+				Status: StatusAdded, Workload: r.Name, Container: r.Containers[i].Name, Right: &r.Containers[i],
+			})
+		}
+	}
+
+	return differences
+}
+
+// compareWorkload compares the containers of a workload existing on both sides
+func compareWorkload(l, r inventory.Workload) []Difference {
+	rightContainers := make(map[string]*inventory.Container, len(r.Containers))
+	for i := range r.Containers {
+		rightContainers[r.Containers[i].Name] = &r.Containers[i]
+	}
+
+	var differences []Difference
+	seen := make(map[string]bool, len(l.Containers))
+	for i := range l.Containers {
+		left := &l.Containers[i]
+		seen[left.Name] = true
+
+		right, exists := rightContainers[left.Name]
+		if !exists {
+			differences = append(differences, Difference{Status: StatusRemoved, Workload: l.Name, Container: left.Name, Left: left})
+			continue
+		}
+		if fields := ChangedFields(*left, *right); len(fields) > 0 {
+			differences = append(differences, Difference{
+				Status: StatusChanged, Workload: l.Name, Container: left.Name, Left: left, Right: right, Fields: fields,
+			})
+		}
+	}
+
+	for i := range r.Containers {
+		if !seen[r.Containers[i].Name] {
+			differences = append(differences, Difference{Status: StatusAdded, Workload: r.Name, Container: r.Containers[i].Name, Right: &r.Containers[i]})
+		}
+	}
+	return differences
+}
+
+// ChangedFields returns which parts of the image differ. Digests are only compared
+// when both are known, a differing digest under the same tag means the tag was moved.
+func ChangedFields(left, right inventory.Container) []string {
+	var fields []string
+	if left.Repository() != right.Repository() {
+		fields = append(fields, "repository")
+	}
+	if left.Tag() != right.Tag() {
+		fields = append(fields, "tag")
+	}
+	if left.Digest != "" && right.Digest != "" && left.Digest != right.Digest {
+		fields = append(fields, "digest")
+	}
+	return fields
+}
@@ -0,0 +1,62 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is the outcome of an operation in one kubeconfig context
+type Result struct {
+	Context string
+	Err     error
+	// Skipped is true when a sequential run stopped before reaching the context
+	Skipped bool
+}
+
+// Run calls fn once per context. Contexts are handled at the same time, or one after
+// the other when sequential, in which case the run stops at the first error.
+// Results are in the order of the contexts.
+func Run(ctx context.Context, contexts []string, sequential bool, fn func(ctx context.Context, contextName string) error) []Result {
+	results := make([]Result, len(contexts))
+	for i, name := range contexts {
+		results[i].Context = name
+	}
+
+	if sequential {
+		stopped := false
+		for i := range results {
+			if stopped {
+				results[i].Skipped = true
+				continue
+			}
+			results[i].Err = fn(ctx, results[i].Context)
+			stopped = results[i].Err != nil
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *Result) {
+			defer wg.Done()
+			result.Err = fn(ctx, result.Context)
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// Count returns the number of contexts that failed and that were skipped
+func Count(results []Result) (int, int) {
+	failed, skipped := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.Err != nil:
+			failed++
+		}
+	}
+	return failed, skipped
+}
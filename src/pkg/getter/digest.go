@@ -0,0 +1,99 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Digests returns the digests the containers of the resource actually run, by container name.
+// They are read from the image IDs reported by running pods using the current images,
+// containers without such a pod are missing from the result.
+func (g *ImageGetter) Digests(ctx context.Context) (map[string]string, error) {
+	resourceType, exists := types.ValidResourceTypes[strings.ToLower(g.options.ResourceType)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
+	}
+
+	var pods []corev1.Pod
+	var template *corev1.PodSpec
+
+	switch resourceType {
+	case types.ResourceTypeDeployment:
+		deployment, err := g.options.Clientset.AppsV1().Deployments(g.options.Namespace).Get(ctx, g.options.ResourceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, g.wrapGetError(types.ResourceTypeDeployment, err)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of deployment %s: %w", g.options.ResourceName, err)
+		}
+		podList, err := g.options.Clientset.CoreV1().Pods(g.options.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods of deployment %s: %w", g.options.ResourceName, err)
+		}
+		pods = podList.Items
+		template = &deployment.Spec.Template.Spec
+	case types.ResourceTypePod:
+		pod, err := g.options.Clientset.CoreV1().Pods(g.options.Namespace).Get(ctx, g.options.ResourceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, g.wrapGetError(types.ResourceTypePod, err)
+		}
+		pods = []corev1.Pod{*pod}
+		template = &pod.Spec
+	default:
+		return nil, fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
+	}
+
+	images := make(map[string]string, len(template.Containers))
+	for _, container := range template.Containers {
+		images[container.Name] = container.Image
+	}
+
+	digests := make(map[string]string)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		// Only pods running the current images tell which digest the tags resolve to
+		podImages := make(map[string]string, len(pod.Spec.Containers))
+		for _, container := range pod.Spec.Containers {
+			podImages[container.Name] = container.Image
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			if _, done := digests[status.Name]; done || podImages[status.Name] != images[status.Name] {
+				continue
+			}
+			if digest := ImageIDDigest(status.ImageID); digest != "" {
+				digests[status.Name] = digest
+			}
+		}
+	}
+
+	return digests, nil
+}
+
+// ImageIDDigest extracts the digest from a container status image ID such as
+// "docker-pullable://nginx@sha256:..." and returns "" when it has none
+func ImageIDDigest(imageID string) string {
+	at := strings.LastIndex(imageID, "@")
+	if at == -1 || !strings.HasPrefix(imageID[at+1:], "sha256:") {
+		return ""
+	}
+	return imageID[at+1:]
+}
+
+// WithDigest pins an image to a digest, keeping its tag for readability, e.g. "nginx:1.25@sha256:..."
+func WithDigest(image, digest string) string {
+	if at := strings.Index(image, "@"); at != -1 {
+		image = image[:at]
+	}
+	return image + "@" + digest
+}
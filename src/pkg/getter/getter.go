@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
 	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -14,6 +18,31 @@ type ImageGetter struct {
 	options *types.Options
 }
 
+// Result holds the images of a Kubernetes resource
+type Result struct {
+	Namespace    string
+	ResourceType types.ResourceType
+	ResourceName string
+
+	// Image is the image of the first container, or of the requested container
+	Image string
+	// Tag is the tag of Image, "latest" when the image has no tag
+	Tag string
+
+	// Containers lists the images of all containers in spec order
+	Containers []ContainerImage
+
+	// Pull credentials of the workload, used for registry lookups
+	ServiceAccountName string
+	ImagePullSecrets   []string
+}
+
+// ContainerImage holds the image of a single container
+type ContainerImage struct {
+	Name  string
+	Image string
+}
+
 // New creates a new ImageGetter
 func New(options *types.Options) *ImageGetter {
 	return &ImageGetter{
@@ -22,43 +51,108 @@ func New(options *types.Options) *ImageGetter {
 }
 
 // Get retrieves the image information of the specified resource
-func (g *ImageGetter) Get() error {
+func (g *ImageGetter) Get(ctx context.Context) (*Result, error) {
 	resourceType, exists := types.ValidResourceTypes[strings.ToLower(g.options.ResourceType)]
 	if !exists {
-		return fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
+		return nil, fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
 	}
 
-	var image string
+	var podSpec *corev1.PodSpec
 	var err error
 
 	switch resourceType {
 	case types.ResourceTypeDeployment:
-		image, err = g.getDeploymentImage()
+		podSpec, err = g.getDeploymentPodSpec(ctx)
 	case types.ResourceTypePod:
-		image, err = g.getPodImage()
+		podSpec, err = g.getPodSpec(ctx)
 	default:
-		return fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
+		return nil, fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	output := image
-	if g.options.TagOnly {
-		output = extractTag(image)
+	return g.result(resourceType, g.options.Namespace, podSpec)
+}
+
+// GetManifest retrieves the image information of the specified resource from local manifests
+func (g *ImageGetter) GetManifest(files []*manifest.File) (*Result, error) {
+	resourceType, exists := types.ValidResourceTypes[strings.ToLower(g.options.ResourceType)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", g.options.ResourceType)
 	}
 
-	// Print just the image string to stdout
-	if output != "" {
-		fmt.Println(output)
+	workload, err := manifest.Find(files, types.ResourceKinds[resourceType], g.options.ResourceName)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	return g.result(resourceType, workload.Namespace, workload.PodSpec)
 }
 
-// extractTag extracts the tag from a full image string.
-// It returns an empty string if no tag is found.
-func extractTag(image string) string {
+// result builds the result from the pod spec of the resource
+func (g *ImageGetter) result(resourceType types.ResourceType, namespace string, podSpec *corev1.PodSpec) (*Result, error) {
+	result := &Result{
+		Namespace:          namespace,
+		ResourceType:       resourceType,
+		ResourceName:       g.options.ResourceName,
+		ServiceAccountName: podSpec.ServiceAccountName,
+	}
+	for _, secret := range podSpec.ImagePullSecrets {
+		result.ImagePullSecrets = append(result.ImagePullSecrets, secret.Name)
+	}
+	for _, container := range podSpec.Containers {
+		result.Containers = append(result.Containers, ContainerImage{
+			Name:  container.Name,
+			Image: container.Image,
+		})
+	}
+
+	container, err := g.selectContainer(resourceType, podSpec)
+	if err != nil {
+		return nil, err
+	}
+	result.Image = container.Image
+	result.Tag = ExtractTag(container.Image)
+
+	return result, nil
+}
+
+// selectContainer returns the requested container, or the first one by default
+func (g *ImageGetter) selectContainer(resourceType types.ResourceType, podSpec *corev1.PodSpec) (*corev1.Container, error) {
+	if len(podSpec.Containers) == 0 {
+		return nil, &types.ContainerNotFoundError{
+			ResourceType: string(resourceType),
+			ResourceName: g.options.ResourceName,
+		}
+	}
+
+	if g.options.ContainerName == "" {
+		return &podSpec.Containers[0], nil
+	}
+
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == g.options.ContainerName {
+			return &podSpec.Containers[i], nil
+		}
+	}
+
+	return nil, &types.ContainerNotFoundError{
+		Container:    g.options.ContainerName,
+		ResourceType: string(resourceType),
+		ResourceName: g.options.ResourceName,
+	}
+}
+
+// ExtractTag extracts the tag from a full image string.
+// It returns "latest" if no tag is found.
+func ExtractTag(image string) string {
+	// Strip the digest, the tag comes before it
+	if at := strings.Index(image, "@"); at != -1 {
+		image = image[:at]
+	}
+
 	// Find the last colon, which separates the tag
 	lastColon := strings.LastIndex(image, ":")
 	if lastColon == -1 {
@@ -73,40 +167,41 @@ func extractTag(image string) string {
 	return image[lastColon+1:]
 }
 
-// getDeploymentImage gets the image of the first container in a deployment
-func (g *ImageGetter) getDeploymentImage() (string, error) {
-	ctx := context.TODO()
+// getDeploymentPodSpec gets the pod template spec of a deployment
+func (g *ImageGetter) getDeploymentPodSpec(ctx context.Context) (*corev1.PodSpec, error) {
 	deploymentsClient := g.options.Clientset.AppsV1().Deployments(g.options.Namespace)
 
 	// Get the deployment
 	deployment, err := deploymentsClient.Get(ctx, g.options.ResourceName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to get deployment %s: %w", g.options.ResourceName, err)
+		return nil, g.wrapGetError(types.ResourceTypeDeployment, err)
 	}
 
-	if len(deployment.Spec.Template.Spec.Containers) == 0 {
-		return "", fmt.Errorf("no containers found in deployment %s", g.options.ResourceName)
-	}
-
-	// Return the image of the first container
-	return deployment.Spec.Template.Spec.Containers[0].Image, nil
+	return &deployment.Spec.Template.Spec, nil
 }
 
-// getPodImage gets the image of the first container in a pod
-func (g *ImageGetter) getPodImage() (string, error) {
-	ctx := context.TODO()
+// getPodSpec gets the spec of a pod
+func (g *ImageGetter) getPodSpec(ctx context.Context) (*corev1.PodSpec, error) {
 	podsClient := g.options.Clientset.CoreV1().Pods(g.options.Namespace)
 
 	// Get the pod
 	pod, err := podsClient.Get(ctx, g.options.ResourceName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to get pod %s: %w", g.options.ResourceName, err)
+		return nil, g.wrapGetError(types.ResourceTypePod, err)
 	}
 
-	if len(pod.Spec.Containers) == 0 {
-		return "", fmt.Errorf("no containers found in pod %s", g.options.ResourceName)
-	}
+	return &pod.Spec, nil
+}
 
-	// Return the image of the first container
-	return pod.Spec.Containers[0].Image, nil
+// wrapGetError turns API errors into typed errors where possible
+func (g *ImageGetter) wrapGetError(resourceType types.ResourceType, err error) error {
+	if apierrors.IsNotFound(err) {
+		return &types.NotFoundError{
+			ResourceType: string(resourceType),
+			Name:         g.options.ResourceName,
+			Namespace:    g.options.Namespace,
+			Err:          err,
+		}
+	}
+	return fmt.Errorf("failed to get %s %s: %w", resourceType, g.options.ResourceName, err)
 }
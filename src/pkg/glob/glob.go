@@ -0,0 +1,40 @@
+package glob
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Match reports whether name matches a shell style pattern. Unlike path.Match,
+// "*" also matches "/", so "registry.corp/*" matches every repository of the registry.
+// "?" matches a single character and all other characters match themselves.
+func Match(pattern, name string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == name
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String()).MatchString(name)
+}
+
+// MatchAny reports whether name matches one of the patterns
+func MatchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layout describes how a values file stores an image
+type Layout string
+
+const (
+	// LayoutString is a single value holding the full image, e.g. image: nginx:1.25
+	LayoutString Layout = "string"
+	// LayoutFields splits the image into repository, tag, digest and an optional registry
+	LayoutFields Layout = "fields"
+)
+
+// repositoryKeys are the keys charts use for the repository, in order of preference
+var repositoryKeys = []string{"repository", "name"}
+
+// Values is a Helm values file
+type Values struct {
+	File *manifest.File
+}
+
+// Image is an image found in a values file
+type Image struct {
+	Path   string
+	Layout Layout
+	// Image is the full image the values describe
+	Image string
+
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Load reads a values file
+func Load(path string) (*Values, error) {
+	file, err := manifest.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Documents()) == 0 {
+		return nil, fmt.Errorf("values file %s is empty", path)
+	}
+	return &Values{File: file}, nil
+}
+
+// Save writes the values file back
+func (v *Values) Save() error {
+	return v.File.Save(nil)
+}
+
+// Image returns the image at a dotted path such as "app.image". The path may also point to
+// the parent of an "image" key, e.g. "app" for app.image.repository and app.image.tag.
+func (v *Values) Image(path string) (*Image, error) {
+	node, path, err := v.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &Image{Path: path}
+	if node.Kind == yaml.ScalarNode {
+		if node.Value == "" {
+			return nil, fmt.Errorf("%s: %s is empty", v.File.Path, path)
+		}
+		image.Layout = LayoutString
+		image.Image = node.Value
+		return image, nil
+	}
+
+	image.Layout = LayoutFields
+	image.Registry = scalar(manifest.Lookup(node, "registry"))
+	image.Repository = scalar(repositoryNode(node))
+	image.Tag = scalar(manifest.Lookup(node, "tag"))
+	image.Digest = scalar(manifest.Lookup(node, "digest"))
+
+	image.Image = image.Repository
+	if image.Registry != "" {
+		image.Image = image.Registry + "/" + image.Image
+	}
+	if image.Tag != "" {
+		image.Image += ":" + image.Tag
+	}
+	if image.Digest != "" {
+		image.Image += "@" + image.Digest
+	}
+	return image, nil
+}
+
+// SetImage changes the image at a path, keeping the layout of the values.
+// Only the changed values are rewritten, so comments and formatting are kept.
+func (v *Values) SetImage(path, image string) error {
+	current, err := v.Image(path)
+	if err != nil {
+		return err
+	}
+	node, path, err := v.lookup(path)
+	if err != nil {
+		return err
+	}
+	if current.Layout == LayoutString {
+		return v.File.SetScalar(node, image)
+	}
+
+	registry, repository, tag, digest := splitImage(image)
+	switch {
+	case manifest.Lookup(node, "registry") == nil:
+		// Without a registry key the registry stays part of the repository
+		if registry != "" {
+			repository, registry = registry+"/"+repository, ""
+		}
+	case registry == "" && current.Registry != "":
+		registry = "docker.io"
+	}
+
+	fields := []struct {
+		keys  []string
+		value string
+	}{
+		{[]string{"registry"}, registry},
+		{repositoryKeys, repository},
+		{[]string{"tag"}, tag},
+		{[]string{"digest"}, digest},
+	}
+	for _, field := range fields {
+		// Nodes change with every edit, so the image is looked up again each time
+		node, _, err := v.lookup(path)
+		if err != nil {
+			return err
+		}
+
+		var value *yaml.Node
+		for _, key := range field.keys {
+			if value = manifest.Lookup(node, key); value != nil {
+				break
+			}
+		}
+
+		switch {
+		case value != nil && value.Value != field.value:
+			err = v.File.SetScalar(value, field.value)
+		case value == nil && field.value != "":
+			err = v.File.InsertKey(node, field.keys[0], field.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the image node at a dotted path and the path it was found at
+func (v *Values) lookup(path string) (*yaml.Node, string, error) {
+	root := v.File.Documents()[0]
+	if len(root.Content) == 0 {
+		return nil, "", fmt.Errorf("values file %s is empty", v.File.Path)
+	}
+
+	keys := strings.Split(path, ".")
+	node := manifest.Lookup(root.Content[0], keys...)
+	if node == nil {
+		return nil, "", fmt.Errorf("%s has no value at %s", v.File.Path, path)
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!str" {
+			return node, path, nil
+		}
+	case yaml.MappingNode:
+		if repositoryNode(node) != nil {
+			return node, path, nil
+		}
+		if image := manifest.Lookup(node, "image"); image != nil {
+			return v.lookup(path + ".image")
+		}
+	}
+	return nil, "", fmt.Errorf("%s: no image found at %s, expected an image string or repository/tag values", v.File.Path, path)
+}
+
+// repositoryNode returns the repository of a fields layout, nil if there is none
+func repositoryNode(node *yaml.Node) *yaml.Node {
+	for _, key := range repositoryKeys {
+		if repository := manifest.Lookup(node, key); repository != nil && repository.Kind == yaml.ScalarNode {
+			return repository
+		}
+	}
+	return nil
+}
+
+// scalar returns the value of a scalar node, "" for null and other nodes
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// splitImage splits an image into registry, repository, tag and digest without normalizing it.
+// The registry is only set when the first path component is a host, e.g. "ghcr.io" or "localhost:5000".
+func splitImage(image string) (string, string, string, string) {
+	var digest, tag string
+	if at := strings.Index(image, "@"); at != -1 {
+		image, digest = image[:at], image[at+1:]
+	}
+	if colon := strings.LastIndex(image, ":"); colon != -1 && !strings.Contains(image[colon+1:], "/") {
+		image, tag = image[:colon], image[colon+1:]
+	}
+
+	if slash := strings.Index(image, "/"); slash != -1 {
+		host := image[:slash]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			return host, image[slash+1:], tag, digest
+		}
+	}
+	return "", image, tag, digest
+}
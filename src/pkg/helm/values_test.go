@@ -0,0 +1,172 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadValues writes a values file to a temporary directory and loads it
+func loadValues(t *testing.T, content string) *Values {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	values, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return values
+}
+
+func TestSetImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		values string
+		path   string
+		image  string
+		want   string
+		// wantImage is the image read back, the image that was set when empty
+		wantImage string
+	}{
+		{
+			name:   "string",
+			values: "app:\n  image: nginx:1.0 # upstream\n  replicas: 2\n",
+			path:   "app.image",
+			image:  "nginx:1.1",
+			want:   "app:\n  image: nginx:1.1 # upstream\n  replicas: 2\n",
+		},
+		{
+			name:   "quoted string",
+			values: "image: \"registry.corp/app:1.0\"\n",
+			path:   "image",
+			image:  "registry.corp/app:1.1",
+			want:   "image: \"registry.corp/app:1.1\"\n",
+		},
+		{
+			name:   "fields with a numeric tag",
+			values: "image:\n  repository: nginx\n  tag: 1.0 # app version\n  pullPolicy: IfNotPresent\n",
+			path:   "image",
+			image:  "nginx:1.1",
+			want:   "image:\n  repository: nginx\n  tag: \"1.1\" # app version\n  pullPolicy: IfNotPresent\n",
+		},
+		{
+			name:   "parent of the image key",
+			values: "app:\n  image:\n    repository: nginx\n    tag: \"1.0\"\n",
+			path:   "app",
+			image:  "nginx:1.1",
+			want:   "app:\n  image:\n    repository: nginx\n    tag: \"1.1\"\n",
+		},
+		{
+			name:   "empty tag",
+			values: "image:\n  repository: nginx\n  tag: \"\"\n",
+			path:   "image",
+			image:  "nginx:1.1",
+			want:   "image:\n  repository: nginx\n  tag: \"1.1\"\n",
+		},
+		{
+			name:   "null tag",
+			values: "image:\n  repository: nginx\n  tag:\n",
+			path:   "image",
+			image:  "nginx:1.1",
+			want:   "image:\n  repository: nginx\n  tag: \"1.1\"\n",
+		},
+		{
+			name:   "missing tag",
+			values: "image:\n  repository: nginx\n  pullPolicy: Always\n",
+			path:   "image",
+			image:  "nginx:1.1",
+			want:   "image:\n  repository: nginx\n  pullPolicy: Always\n  tag: \"1.1\"\n",
+		},
+		{
+			name:   "name instead of repository",
+			values: "image:\n  name: team/app\n  tag: v1\n",
+			path:   "image",
+			image:  "team/web:v2",
+			want:   "image:\n  name: team/web\n  tag: v2\n",
+		},
+		{
+			name:   "registry key",
+			values: "image:\n  registry: docker.io\n  repository: team/app\n  tag: v1\n",
+			path:   "image",
+			image:  "ghcr.io/team/app:v2",
+			want:   "image:\n  registry: ghcr.io\n  repository: team/app\n  tag: v2\n",
+		},
+		{
+			name:      "registry key without a registry in the image",
+			values:    "image:\n  registry: ghcr.io\n  repository: team/app\n  tag: v1\n",
+			path:      "image",
+			image:     "team/app:v2",
+			want:      "image:\n  registry: docker.io\n  repository: team/app\n  tag: v2\n",
+			wantImage: "docker.io/team/app:v2",
+		},
+		{
+			name:   "registry in the repository",
+			values: "image:\n  repository: nginx\n  tag: v1\n",
+			path:   "image",
+			image:  "ghcr.io/team/app:v2",
+			want:   "image:\n  repository: ghcr.io/team/app\n  tag: v2\n",
+		},
+		{
+			name:   "digest",
+			values: "image:\n  repository: nginx\n  tag: v1\n",
+			path:   "image",
+			image:  "nginx@sha256:abc",
+			want:   "image:\n  repository: nginx\n  tag: \"\"\n  digest: sha256:abc\n",
+		},
+		{
+			name:   "anchored",
+			values: "defaults:\n  tag: &tag v1\nimage:\n  repository: nginx\n  tag: *tag\nsidecar:\n  repository: envoy\n  tag: &sidecar v1\n",
+			path:   "sidecar",
+			image:  "envoy:v2",
+			want:   "defaults:\n  tag: &tag v1\nimage:\n  repository: nginx\n  tag: *tag\nsidecar:\n  repository: envoy\n  tag: &sidecar v2\n",
+		},
+		{
+			name:   "CRLF",
+			values: "image:\r\n  repository: nginx\r\n  pullPolicy: Always\r\n",
+			path:   "image",
+			image:  "nginx:1.1",
+			want:   "image:\r\n  repository: nginx\r\n  pullPolicy: Always\r\n  tag: \"1.1\"\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := loadValues(t, tt.values)
+			if err := values.SetImage(tt.path, tt.image); err != nil {
+				t.Fatalf("SetImage: %v", err)
+			}
+			if got := string(values.File.Data); got != tt.want {
+				t.Errorf("values =\n%q\nwant\n%q", got, tt.want)
+			}
+
+			image, err := values.Image(tt.path)
+			if err != nil {
+				t.Fatalf("Image: %v", err)
+			}
+			want := tt.wantImage
+			if want == "" {
+				want = tt.image
+			}
+			if image.Image != want {
+				t.Errorf("image reads back as %s, want %s", image.Image, want)
+			}
+		})
+	}
+}
+
+func TestSetImageRejectsNonImages(t *testing.T) {
+	values := loadValues(t, "replicas: 2\nresources:\n  limits:\n    cpu: 1\n")
+
+	for _, path := range []string{"replicas", "resources", "missing"} {
+		err := values.SetImage(path, "nginx:1.1")
+		if err == nil {
+			t.Errorf("SetImage(%s) succeeded, want an error", path)
+		}
+	}
+	if !strings.HasPrefix(string(values.File.Data), "replicas: 2\n") {
+		t.Errorf("values changed: %q", values.File.Data)
+	}
+}
@@ -0,0 +1,125 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Workload is a deployment and the images of its containers
+type Workload struct {
+	Namespace  string      `json:"namespace"`
+	Name       string      `json:"name"`
+	Containers []Container `json:"containers"`
+}
+
+// Container is the image of a single container
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Digest is the digest the image is pinned to, or the digest its running pods report
+	Digest string `json:"digest,omitempty"`
+}
+
+// Repository returns the normalized repository of the image, e.g. docker.io/library/nginx for nginx
+func (c Container) Repository() string {
+	if ref, err := reference.Parse(c.Image); err == nil {
+		return ref.Name()
+	}
+	return c.Image
+}
+
+// Tag returns the tag of the image, "latest" when it has none
+func (c Container) Tag() string {
+	return getter.ExtractTag(c.Image)
+}
+
+// Collect lists the deployments of the namespace, or of all namespaces, matching the label selector.
+// Digests are read from the running pods, so a moving tag shows up as a different digest.
+func Collect(ctx context.Context, options *types.Options) ([]Workload, error) {
+	namespace := options.Namespace
+	if options.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	deploymentList, err := options.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{LabelSelector: options.Selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	podList, err := options.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	workloads := make([]Workload, 0, len(deploymentList.Items))
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		digests := runningDigests(deployment, podList.Items)
+
+		workload := Workload{Namespace: deployment.Namespace, Name: deployment.Name}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			c := Container{Name: container.Name, Image: container.Image, Digest: digests[container.Name]}
+			if ref, err := reference.Parse(container.Image); err == nil && ref.Digest != "" {
+				c.Digest = ref.Digest
+			}
+			workload.Containers = append(workload.Containers, c)
+		}
+		workloads = append(workloads, workload)
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+	return workloads, nil
+}
+
+// runningDigests returns the digests reported by the running pods of a deployment that use its current images
+func runningDigests(deployment *appsv1.Deployment, pods []corev1.Pod) map[string]string {
+	digests := make(map[string]string)
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil || selector.Empty() {
+		return digests
+	}
+
+	images := make(map[string]string)
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		images[container.Name] = container.Image
+	}
+
+	for _, pod := range pods {
+		if pod.Namespace != deployment.Namespace || pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		podImages := make(map[string]string, len(pod.Spec.Containers))
+		for _, container := range pod.Spec.Containers {
+			podImages[container.Name] = container.Image
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if _, done := digests[status.Name]; done || podImages[status.Name] != images[status.Name] {
+				continue
+			}
+			if digest := getter.ImageIDDigest(status.ImageID); digest != "" {
+				digests[status.Name] = digest
+			}
+		}
+	}
+
+	return digests
+}
@@ -0,0 +1,353 @@
+package kustomize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileNames are the names kustomize looks for in a kustomization directory
+var fileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Image is an entry of the images transformer of a kustomization
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// Apply transforms an image the way kustomize does when the entry matches its name.
+// A digest replaces the tag, and the second result is false when the entry does not match.
+func (i Image) Apply(image string) (string, bool) {
+	name, tag, digest := splitImage(image)
+	if name != i.Name {
+		return image, false
+	}
+
+	if i.NewName != "" {
+		name = i.NewName
+	}
+	switch {
+	case i.Digest != "":
+		return name + "@" + i.Digest, true
+	case i.NewTag != "":
+		return name + ":" + i.NewTag, true
+	case digest != "":
+		return name + "@" + digest, true
+	case tag != "":
+		return name + ":" + tag, true
+	default:
+		return name, true
+	}
+}
+
+// Kustomization is a kustomization file and the settings relevant for images
+type Kustomization struct {
+	Dir  string         `yaml:"-"`
+	File *manifest.File `yaml:"-"`
+
+	Images     []Image  `yaml:"images"`
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+}
+
+// Load reads the kustomization file of a directory
+func Load(dir string) (*Kustomization, error) {
+	for _, name := range fileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		file, err := manifest.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		k := &Kustomization{Dir: dir, File: file}
+		if err := yaml.Unmarshal(file.Data, k); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("no kustomization file found in %s", dir)
+}
+
+// Save writes the kustomization file back
+func (k *Kustomization) Save() error {
+	return k.File.Save(nil)
+}
+
+// usage is an image used by a container, the image it originally had in the resources
+// and the image it had before the images transformer of the kustomization itself
+type usage struct {
+	original string
+	input    string
+	image    string
+}
+
+// Effective returns the distinct images that containers of the kustomization use for an image name,
+// after the images transformers of all bases and of the kustomization itself are applied.
+// Images whose original or transformed name matches are returned. When no resource uses the image,
+// for example because it only comes from remote bases, the transformers are applied to the bare name.
+func (k *Kustomization) Effective(name string) ([]string, error) {
+	usages, err := k.usages(map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+	for _, u := range usages {
+		originalName, _, _ := splitImage(u.original)
+		currentName, _, _ := splitImage(u.image)
+		if (originalName == name || currentName == name) && !seen[u.image] {
+			seen[u.image] = true
+			images = append(images, u.image)
+		}
+	}
+	if len(images) > 0 {
+		return images, nil
+	}
+
+	transformers, err := k.transformers(map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	image := name
+	for _, images := range transformers {
+		image = apply(images, image)
+	}
+	return []string{image}, nil
+}
+
+// usages returns the container images of the resources after the images transformers are applied
+func (k *Kustomization) usages(visited map[string]bool) ([]usage, error) {
+	var usages []usage
+	err := k.walk(visited, func(base *Kustomization) error {
+		found, err := base.usages(visited)
+		usages = append(usages, found...)
+		return err
+	}, func(file *manifest.File) {
+		for _, image := range file.Images() {
+			usages = append(usages, usage{original: image, image: image})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range usages {
+		usages[i].input = usages[i].image
+		usages[i].image = apply(k.Images, usages[i].image)
+	}
+	return usages, nil
+}
+
+// transformers returns the images transformers from the innermost base to the kustomization itself
+func (k *Kustomization) transformers(visited map[string]bool) ([][]Image, error) {
+	var transformers [][]Image
+	err := k.walk(visited, func(base *Kustomization) error {
+		found, err := base.transformers(visited)
+		transformers = append(transformers, found...)
+		return err
+	}, func(*manifest.File) {})
+	if err != nil {
+		return nil, err
+	}
+	return append(transformers, k.Images), nil
+}
+
+// walk calls onBase for the kustomizations and onFile for the manifest files the kustomization includes
+func (k *Kustomization) walk(visited map[string]bool, onBase func(*Kustomization) error, onFile func(*manifest.File)) error {
+	dir, err := filepath.Abs(k.Dir)
+	if err != nil {
+		return err
+	}
+	if visited[dir] {
+		return fmt.Errorf("kustomization %s is included in a cycle", k.Dir)
+	}
+	visited[dir] = true
+	defer delete(visited, dir)
+
+	entries := append(append(append([]string{}, k.Resources...), k.Bases...), k.Components...)
+	for _, entry := range entries {
+		// Remote resources cannot be read offline
+		if strings.Contains(entry, "://") || strings.HasPrefix(entry, "github.com/") {
+			continue
+		}
+
+		path := filepath.Join(k.Dir, entry)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("resource %s of %s: %w", entry, k.File.Path, err)
+		}
+		if info.IsDir() {
+			base, err := Load(path)
+			if err != nil {
+				return err
+			}
+			if err := onBase(base); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file, err := manifest.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		onFile(file)
+	}
+	return nil
+}
+
+// apply applies the first matching entry of an images transformer
+func apply(images []Image, image string) string {
+	for _, entry := range images {
+		if transformed, matched := entry.Apply(image); matched {
+			return transformed
+		}
+	}
+	return image
+}
+
+// SetImage adds or updates the images entry of name so that it results in image.
+// Existing entries are edited in place, keeping comments and formatting of the file.
+func (k *Kustomization) SetImage(name, image string) error {
+	name, err := k.entryName(name)
+	if err != nil {
+		return err
+	}
+
+	newName, tag, digest := splitImage(image)
+	if newName == name {
+		newName = ""
+	}
+	if digest != "" {
+		tag = ""
+	}
+	fields := []struct{ key, value string }{
+		{"newName", newName},
+		{"newTag", tag},
+		{"digest", digest},
+	}
+
+	entry := &yaml.Node{Kind: yaml.MappingNode}
+	entry.Content = append(entry.Content, stringNode("name"), stringNode(name))
+	for _, field := range fields {
+		if field.value != "" {
+			entry.Content = append(entry.Content, stringNode(field.key), stringNode(field.value))
+		}
+	}
+
+	root := k.root()
+	if root == nil {
+		return fmt.Errorf("%s is not a kustomization", k.File.Path)
+	}
+	images := manifest.Lookup(root, "images")
+	if images == nil {
+		if err := k.File.InsertKey(root, "images", []*yaml.Node{entry}); err != nil {
+			return err
+		}
+		return k.reload()
+	}
+
+	if k.entry(name) == nil {
+		if err := k.File.AppendItem(images, entry); err != nil {
+			return err
+		}
+		return k.reload()
+	}
+
+	// Nodes change with every edit, so the entry is looked up again each time
+	for _, field := range fields {
+		node := manifest.Lookup(k.entry(name), field.key)
+		var err error
+		switch {
+		case field.value == "" && node != nil:
+			err = k.File.DeleteKey(k.entry(name), field.key)
+		case field.value != "" && node == nil:
+			err = k.File.InsertKey(k.entry(name), field.key, field.value)
+		case field.value != "" && node.Value != field.value:
+			err = k.File.SetScalar(node, field.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return k.reload()
+}
+
+// entryName returns the name an images entry needs to match the image, which differs from the
+// given name when a base already renamed it, e.g. "registry.local/app" for "app"
+func (k *Kustomization) entryName(name string) (string, error) {
+	if k.entry(name) != nil {
+		return name, nil
+	}
+
+	usages, err := k.usages(map[string]bool{})
+	if err != nil {
+		return "", err
+	}
+	for _, u := range usages {
+		originalName, _, _ := splitImage(u.original)
+		inputName, _, _ := splitImage(u.input)
+		if originalName == name || inputName == name {
+			return inputName, nil
+		}
+	}
+	return name, nil
+}
+
+// root returns the top level mapping of the kustomization file
+func (k *Kustomization) root() *yaml.Node {
+	docs := k.File.Documents()
+	if len(docs) == 0 || len(docs[0].Content) == 0 {
+		return nil
+	}
+	return docs[0].Content[0]
+}
+
+// entry returns the images entry of name, nil if there is none
+func (k *Kustomization) entry(name string) *yaml.Node {
+	images := manifest.Lookup(k.root(), "images")
+	if images == nil || images.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range images.Content {
+		if node := manifest.Lookup(item, "name"); node != nil && node.Value == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// reload decodes the kustomization again after its file was edited
+func (k *Kustomization) reload() error {
+	k.Images = nil
+	return yaml.Unmarshal(k.File.Data, k)
+}
+
+// stringNode returns a string scalar node
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// splitImage splits an image into name, tag and digest without normalizing the name
+func splitImage(image string) (string, string, string) {
+	var digest string
+	if at := strings.Index(image, "@"); at != -1 {
+		image, digest = image[:at], image[at+1:]
+	}
+	if colon := strings.LastIndex(image, ":"); colon != -1 && !strings.Contains(image[colon+1:], "/") {
+		return image[:colon], image[colon+1:], digest
+	}
+	return image, "", digest
+}
@@ -0,0 +1,151 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// deployment is a resource running the app image of the registry.corp team
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.corp/team/app:1.0
+`
+
+// writeFiles writes files below a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSetImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization string
+		image         string
+		want          string
+	}{
+		{
+			name:          "update the tag",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app # the app\n  newTag: \"1.0\" # set by CI\n",
+			image:         "registry.corp/team/app:1.1",
+			want:          "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app # the app\n  newTag: \"1.1\" # set by CI\n",
+		},
+		{
+			name:          "replace the tag with a digest",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newTag: v1\n",
+			image:         "registry.corp/team/app@sha256:abc",
+			want:          "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  digest: sha256:abc\n",
+		},
+		{
+			name:          "rename",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newTag: v1\n",
+			image:         "mirror.corp/team/app:v2",
+			want:          "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newTag: v2\n  newName: mirror.corp/team/app\n",
+		},
+		{
+			name:          "drop a rename back to the original name",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newName: mirror.corp/team/app\n  newTag: v1\n",
+			image:         "registry.corp/team/app:v2",
+			want:          "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newTag: v2\n",
+		},
+		{
+			name:          "append an entry",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n  - name: other\n    newTag: v1\n",
+			image:         "registry.corp/team/app:1.1",
+			want:          "resources:\n- deployment.yaml\nimages:\n  - name: other\n    newTag: v1\n  - name: registry.corp/team/app\n    newTag: \"1.1\"\n",
+		},
+		{
+			name:          "add the images transformer",
+			kustomization: "# overlay\nresources:\n- deployment.yaml\n",
+			image:         "registry.corp/team/app:1.1",
+			want:          "# overlay\nresources:\n- deployment.yaml\nimages:\n  - name: registry.corp/team/app\n    newTag: \"1.1\"\n",
+		},
+		{
+			name:          "CRLF",
+			kustomization: "resources:\r\n- deployment.yaml\r\nimages:\r\n- name: registry.corp/team/app\r\n  newTag: v1\r\n",
+			image:         "registry.corp/team/app@sha256:abc",
+			want:          "resources:\r\n- deployment.yaml\r\nimages:\r\n- name: registry.corp/team/app\r\n  digest: sha256:abc\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"kustomization.yaml": tt.kustomization,
+				"deployment.yaml":    deployment,
+			})
+			k, err := Load(dir)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			if err := k.SetImage("registry.corp/team/app", tt.image); err != nil {
+				t.Fatalf("SetImage: %v", err)
+			}
+			if string(k.File.Data) != tt.want {
+				t.Errorf("kustomization =\n%q\nwant\n%q", k.File.Data, tt.want)
+			}
+
+			// The edited transformer results in the new image
+			effective, err := k.Effective("registry.corp/team/app")
+			if err != nil {
+				t.Fatalf("Effective: %v", err)
+			}
+			if !slices.Equal(effective, []string{tt.image}) {
+				t.Errorf("effective images = %v, want %s", effective, tt.image)
+			}
+		})
+	}
+}
+
+func TestSetImageOfRenamedBaseImage(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/kustomization.yaml":          "resources:\n- deployment.yaml\nimages:\n- name: registry.corp/team/app\n  newName: mirror.corp/team/app\n",
+		"base/deployment.yaml":             deployment,
+		"overlays/prod/kustomization.yaml": "resources:\n- ../../base\n",
+	})
+	k, err := Load(filepath.Join(dir, "overlays", "prod"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// The overlay has to match the name the base renamed the image to
+	if err := k.SetImage("registry.corp/team/app", "mirror.corp/team/app:2.0"); err != nil {
+		t.Fatalf("SetImage: %v", err)
+	}
+	want := "resources:\n- ../../base\nimages:\n  - name: mirror.corp/team/app\n    newTag: \"2.0\"\n"
+	if string(k.File.Data) != want {
+		t.Errorf("kustomization =\n%q\nwant\n%q", k.File.Data, want)
+	}
+
+	if err := k.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, "overlays", "prod", "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), "newTag: \"2.0\"") {
+		t.Errorf("saved kustomization = %q", saved)
+	}
+}
@@ -0,0 +1,155 @@
+package lockfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/inventory"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// APIVersion is the version of the lock file format
+	APIVersion = "kubectl-image/v1"
+	// Kind identifies lock files
+	Kind = "ImageLock"
+)
+
+// File is an image lock file recording the image of every workload container
+type File struct {
+	APIVersion string     `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string     `json:"kind" yaml:"kind"`
+	Workloads  []Workload `json:"workloads" yaml:"workloads"`
+}
+
+// Workload is a deployment in the lock file
+type Workload struct {
+	Namespace  string      `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name       string      `json:"name" yaml:"name"`
+	Containers []Container `json:"containers" yaml:"containers"`
+}
+
+// Container is the locked image of a container, pinned to a digest when it is known
+type Container struct {
+	Name  string `json:"name" yaml:"name"`
+	Image string `json:"image" yaml:"image"`
+}
+
+// Export creates a lock file from the workloads of a cluster.
+// Images are pinned to the digest the pods run, containers whose digest is unknown
+// are locked by tag and returned as warnings.
+func Export(workloads []inventory.Workload) (*File, []string) {
+	file := &File{APIVersion: APIVersion, Kind: Kind}
+	var warnings []string
+
+	for _, workload := range workloads {
+		locked := Workload{Namespace: workload.Namespace, Name: workload.Name}
+		for _, container := range workload.Containers {
+			image := container.Image
+			if container.Digest != "" {
+				image = getter.WithDigest(image, container.Digest)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s/%s container %s: no running pod reports a digest, locked by tag",
+					workload.Namespace, workload.Name, container.Name))
+			}
+			locked.Containers = append(locked.Containers, Container{Name: container.Name, Image: image})
+		}
+		file.Workloads = append(file.Workloads, locked)
+	}
+
+	return file, warnings
+}
+
+// Write encodes the lock file as YAML
+func (f *File) Write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Load reads a lock file in YAML or JSON format, "-" reads from stdin
+func Load(path string, stdin io.Reader) (*File, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %w", path, err)
+	}
+
+	file := &File{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	if file.Kind != Kind {
+		return nil, fmt.Errorf("%s is not an image lock file (kind %q, expected %s)", path, file.Kind, Kind)
+	}
+	if file.APIVersion != APIVersion {
+		return nil, fmt.Errorf("unsupported lock file version %q in %s (expected %s)", file.APIVersion, path, APIVersion)
+	}
+
+	for _, workload := range file.Workloads {
+		if workload.Name == "" {
+			return nil, fmt.Errorf("lock file %s has a workload without name", path)
+		}
+		for _, container := range workload.Containers {
+			if container.Name == "" || container.Image == "" {
+				return nil, fmt.Errorf("lock file %s: workload %s has a container without name or image", path, workload.Name)
+			}
+		}
+	}
+	return file, nil
+}
+
+// Inventory returns the locked workloads of a namespace, all of them for an empty namespace,
+// in the form used to compare them with a cluster. Workloads without namespace belong to defaultNamespace.
+func (f *File) Inventory(namespace, defaultNamespace string) []inventory.Workload {
+	var workloads []inventory.Workload
+	for _, workload := range f.Workloads {
+		ns := workload.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		if namespace != "" && ns != namespace {
+			continue
+		}
+
+		converted := inventory.Workload{Namespace: ns, Name: workload.Name}
+		for _, container := range workload.Containers {
+			c := inventory.Container{Name: container.Name, Image: container.Image}
+			if ref, err := reference.Parse(container.Image); err == nil {
+				c.Digest = ref.Digest
+			}
+			converted.Containers = append(converted.Containers, c)
+		}
+		workloads = append(workloads, converted)
+	}
+	return workloads
+}
+
+// Namespaces returns the namespaces of the locked workloads in order of appearance
+func (f *File) Namespaces(defaultNamespace string) []string {
+	var namespaces []string
+	seen := make(map[string]bool)
+	for _, workload := range f.Workloads {
+		ns := workload.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		if !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
@@ -0,0 +1,266 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The edits below change the raw bytes of a file instead of encoding its nodes again,
+// so everything around the edited values stays exactly as it was. Each edit parses the
+// file again, nodes obtained before an edit must not be used afterwards.
+
+// SetScalar replaces the value of a scalar node in the file, keeping its quoting style.
+// Plain values that would not read back as the same string, such as "1.0", are quoted.
+func (f *File) SetScalar(node *yaml.Node, value string) error {
+	start, end, err := f.scalarSpan(node)
+	if err != nil {
+		return err
+	}
+
+	var replacement string
+	switch {
+	case start == end:
+		// An empty value such as "tag:" gets its value after the colon
+		replacement = " " + doubleQuote(value)
+		if isPlainString(value) {
+			replacement = " " + value
+		}
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		replacement = doubleQuote(value)
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		replacement = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case !isPlainString(value):
+		replacement = doubleQuote(value)
+	default:
+		replacement = value
+	}
+
+	return f.replace(start, end, replacement)
+}
+
+// InsertKey adds a key to a block mapping, after its last entry and with the same indentation.
+// The value is encoded as YAML, e.g. a string or a list of *yaml.Node mappings.
+func (f *File) InsertKey(mapping *yaml.Node, key string, value interface{}) error {
+	if mapping.Kind != yaml.MappingNode || mapping.Style&yaml.FlowStyle != 0 || len(mapping.Content) == 0 {
+		return fmt.Errorf("%s:%d: cannot add %s, only non-empty block mappings can be extended", f.Path, mapping.Line, key)
+	}
+
+	valueNode := &yaml.Node{}
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+	entry := &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, valueNode},
+	}
+
+	text, err := encodeBlock(entry, mapping.Content[0].Column-1)
+	if err != nil {
+		return err
+	}
+	offset := f.lineStart(lastLine(mapping) + 1)
+	return f.replace(offset, offset, f.lineEndings(text))
+}
+
+// AppendItem adds an item to the end of a block sequence, with the same indentation as its first item
+func (f *File) AppendItem(sequence *yaml.Node, item *yaml.Node) error {
+	if sequence.Kind != yaml.SequenceNode || sequence.Style&yaml.FlowStyle != 0 || len(sequence.Content) == 0 {
+		return fmt.Errorf("%s:%d: cannot add an item, only non-empty block sequences can be extended", f.Path, sequence.Line)
+	}
+
+	// The first item's line starts with the indentation of the dash
+	first := f.lineStart(sequence.Content[0].Line)
+	indent := 0
+	for first+indent < len(f.Data) && f.Data[first+indent] == ' ' {
+		indent++
+	}
+
+	text, err := encodeBlock(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{item}}, indent)
+	if err != nil {
+		return err
+	}
+	offset := f.lineStart(lastLine(sequence) + 1)
+	return f.replace(offset, offset, f.lineEndings(text))
+}
+
+// DeleteKey removes a key and its value from a block mapping. Nothing happens when the key does not exist.
+func (f *File) DeleteKey(mapping *yaml.Node, key string) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+		if keyNode.Value != key {
+			continue
+		}
+
+		start := f.lineStart(keyNode.Line)
+		if mapping.Style&yaml.FlowStyle != 0 || strings.TrimSpace(string(f.Data[start:f.offset(keyNode.Line, keyNode.Column)])) != "" {
+			return fmt.Errorf("%s:%d: cannot remove %s, it does not start its own line", f.Path, keyNode.Line, key)
+		}
+		return f.replace(start, f.lineStart(lastLine(valueNode)+1), "")
+	}
+	return nil
+}
+
+// replace replaces a byte range of the file and parses it again
+func (f *File) replace(start, end int, text string) error {
+	data := make([]byte, 0, len(f.Data)-(end-start)+len(text))
+	data = append(data, f.Data[:start]...)
+	data = append(data, text...)
+	data = append(data, f.Data[end:]...)
+	return f.parse(data)
+}
+
+// scalarSpan returns the byte range of a scalar node in the file, including its quotes
+func (f *File) scalarSpan(node *yaml.Node) (int, int, error) {
+	if node.Kind != yaml.ScalarNode {
+		return 0, 0, fmt.Errorf("%s:%d: expected a string value", f.Path, node.Line)
+	}
+
+	start := f.skipProperties(f.offset(node.Line, node.Column))
+	switch {
+	case node.Tag == "!!null" && node.Value == "":
+		return start, start, nil
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		if start >= len(f.Data) || f.Data[start] != '"' {
+			break
+		}
+		for i := start + 1; i < len(f.Data); i++ {
+			switch f.Data[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, nil
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		if start >= len(f.Data) || f.Data[start] != '\'' {
+			break
+		}
+		for i := start + 1; i < len(f.Data); i++ {
+			if f.Data[i] != '\'' {
+				continue
+			}
+			if i+1 < len(f.Data) && f.Data[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start, i + 1, nil
+		}
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		if bytes.HasPrefix(f.Data[start:], []byte(node.Value)) {
+			return start, start + len(node.Value), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%s:%d: cannot rewrite the multi-line value %q in place", f.Path, node.Line, node.Value)
+}
+
+// skipProperties returns the offset of a node's value after its anchor and tag, if any,
+// since the position of a node such as "&img !!str nginx" is that of its first property
+func (f *File) skipProperties(offset int) int {
+	for offset < len(f.Data) && (f.Data[offset] == '&' || f.Data[offset] == '!') {
+		for offset < len(f.Data) && !isSpace(f.Data[offset]) {
+			offset++
+		}
+		for offset < len(f.Data) && isSpace(f.Data[offset]) {
+			offset++
+		}
+	}
+	return offset
+}
+
+// isSpace tells whether a byte separates node properties from the value
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// lineEndings converts the newlines of inserted text to CRLF when the file uses them
+func (f *File) lineEndings(text string) string {
+	if !bytes.Contains(f.Data, []byte("\r\n")) {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
+
+// lineStart returns the byte offset of a line, the end of the file when it has fewer lines.
+// A missing final newline is added, so text can always be inserted at a line start.
+func (f *File) lineStart(line int) int {
+	start := 0
+	for current := 1; current < line; current++ {
+		next := bytes.IndexByte(f.Data[start:], '\n')
+		if next == -1 {
+			if len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
+				f.Data = append(f.Data, f.lineEndings("\n")...)
+			}
+			return len(f.Data)
+		}
+		start += next + 1
+	}
+	return start
+}
+
+// offset returns the byte offset of a position, whose column counts characters rather than bytes
+func (f *File) offset(line, column int) int {
+	offset := f.lineStart(line)
+	for current := 1; current < column && offset < len(f.Data); current++ {
+		_, size := utf8.DecodeRune(f.Data[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// lastLine returns the last line a node spans
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		last += strings.Count(node.Value, "\n")
+	}
+	for _, child := range node.Content {
+		if line := lastLine(child); line > last {
+			last = line
+		}
+	}
+	return last
+}
+
+// encodeBlock encodes a node as block YAML, every line indented by the given number of spaces
+func encodeBlock(node *yaml.Node, indent int) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.SplitAfter(buf.String(), "\n")
+	var text strings.Builder
+	for _, line := range lines {
+		if line != "" {
+			text.WriteString(prefix + line)
+		}
+	}
+	return text.String(), nil
+}
+
+// isPlainString tells whether a value reads back as the same string when written without quotes
+func isPlainString(value string) bool {
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil {
+		return false
+	}
+	s, ok := decoded.(string)
+	return ok && s == value
+}
+
+// doubleQuote returns a value as a double-quoted YAML string
+func doubleQuote(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
@@ -0,0 +1,347 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pod returns a Pod manifest whose app container has the given image line, "\n" separated
+func pod(imageLine string) string {
+	return "kind: Pod\nmetadata:\n  name: web\nspec:\n  containers:\n  - name: app\n    " + imageLine + "\n"
+}
+
+func TestWorkloadSetImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "plain",
+			input: pod("image: nginx:1.0"),
+			want:  pod("image: nginx:1.1"),
+		},
+		{
+			name:  "plain with comment",
+			input: pod("image: nginx:1.0   # pinned by ops"),
+			want:  pod("image: nginx:1.1   # pinned by ops"),
+		},
+		{
+			name:  "double quoted",
+			input: pod(`image: "nginx:1.0"`),
+			want:  pod(`image: "nginx:1.1"`),
+		},
+		{
+			name:  "single quoted",
+			input: pod("image: 'nginx:1.0'"),
+			want:  pod("image: 'nginx:1.1'"),
+		},
+		{
+			name:  "anchored",
+			input: pod("image: &web nginx:1.0"),
+			want:  pod("image: &web nginx:1.1"),
+		},
+		{
+			name:  "tagged and quoted",
+			input: pod(`image: !!str "nginx:1.0"`),
+			want:  pod(`image: !!str "nginx:1.1"`),
+		},
+		{
+			name:  "empty",
+			input: pod("image:"),
+			want:  pod("image: nginx:1.1"),
+		},
+		{
+			name:  "non-ASCII before the value",
+			input: "kind: Pod\nmetadata:\n  name: web\n  annotations: {owner: \"zoë\"}\nspec:\n  containers: [{name: app, image: nginx:1.0}]\n",
+			want:  "kind: Pod\nmetadata:\n  name: web\n  annotations: {owner: \"zoë\"}\nspec:\n  containers: [{name: app, image: nginx:1.1}]\n",
+		},
+		{
+			name:  "flow",
+			input: "kind: Pod\nmetadata: {name: web}\nspec:\n  containers: [{name: app, image: nginx:1.0}, {name: sidecar, image: envoy:1.0}]\n",
+			want:  "kind: Pod\nmetadata: {name: web}\nspec:\n  containers: [{name: app, image: nginx:1.1}, {name: sidecar, image: envoy:1.0}]\n",
+		},
+		{
+			name:  "JSON",
+			input: `{"kind": "Pod", "metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "image": "nginx:1.0"}]}}`,
+			want:  `{"kind": "Pod", "metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "image": "nginx:1.1"}]}}`,
+		},
+		{
+			name:  "CRLF",
+			input: strings.ReplaceAll(pod("image: nginx:1.0 # c"), "\n", "\r\n"),
+			want:  strings.ReplaceAll(pod("image: nginx:1.1 # c"), "\n", "\r\n"),
+		},
+		{
+			name:  "second document",
+			input: "# config\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  image: nginx:1.0\n---\n" + pod("image: nginx:1.0"),
+			want:  "# config\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  image: nginx:1.0\n---\n" + pod("image: nginx:1.1"),
+		},
+		{
+			name:    "literal block",
+			input:   pod("image: |\n      nginx:1.0"),
+			wantErr: "cannot rewrite the multi-line value",
+		},
+		{
+			name:    "alias",
+			input:   "x: &web nginx:1.0\n" + pod("image: *web"),
+			wantErr: "expected a string value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("pod.yaml", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			workload, err := Find([]*File{file}, "Pod", "web")
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+
+			err = workload.SetImage("app", "nginx:1.1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetImage error = %v, want %q", err, tt.wantErr)
+				}
+				if string(file.Data) != tt.input {
+					t.Errorf("file changed after a failed edit:\n%q", file.Data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetImage: %v", err)
+			}
+			if string(file.Data) != tt.want {
+				t.Errorf("file =\n%q\nwant\n%q", file.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetScalarQuotesValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		value string
+		want  string
+	}{
+		{name: "plain number", input: "tag: v1\n", value: "1.0", want: "tag: \"1.0\"\n"},
+		{name: "plain bool", input: "tag: v1\n", value: "true", want: "tag: \"true\"\n"},
+		{name: "plain string", input: "tag: \"1.0\"\n", value: "v1.1", want: "tag: \"v1.1\"\n"},
+		{name: "single quote escaped", input: "tag: 'v1'\n", value: "it's", want: "tag: 'it''s'\n"},
+		{name: "double quote escaped", input: "tag: \"v1\"\n", value: `a"b`, want: "tag: \"a\\\"b\"\n"},
+		{name: "escape in old value", input: "tag: \"v\\\"1\" # c\n", value: "v2", want: "tag: \"v2\" # c\n"},
+		{name: "empty to number", input: "tag:\n", value: "1.0", want: "tag: \"1.0\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("values.yaml", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			node := Lookup(file.Documents()[0].Content[0], "tag")
+			if err := file.SetScalar(node, tt.value); err != nil {
+				t.Fatalf("SetScalar: %v", err)
+			}
+			if string(file.Data) != tt.want {
+				t.Errorf("file = %q, want %q", file.Data, tt.want)
+			}
+
+			// The new value must read back unchanged
+			var decoded map[string]string
+			if err := yaml.Unmarshal(file.Data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if decoded["tag"] != tt.value {
+				t.Errorf("value reads back as %q, want %q", decoded["tag"], tt.value)
+			}
+		})
+	}
+}
+
+func TestInsertKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		path    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "after the last entry",
+			input: "image:\n  repository: nginx # upstream\n  pullPolicy: Always\nreplicas: 2\n",
+			path:  []string{"image"},
+			want:  "image:\n  repository: nginx # upstream\n  pullPolicy: Always\n  tag: \"1.0\"\nreplicas: 2\n",
+		},
+		{
+			name:  "before trailing comments",
+			input: "image:\n  repository: nginx\n  # tag: set by CI\n",
+			path:  []string{"image"},
+			want:  "image:\n  repository: nginx\n  tag: \"1.0\"\n  # tag: set by CI\n",
+		},
+		{
+			name:  "after a block scalar",
+			input: "image:\n  notes: |\n    first\n\n    second\nother: 1\n",
+			path:  []string{"image"},
+			want:  "image:\n  notes: |\n    first\n\n    second\n  tag: \"1.0\"\nother: 1\n",
+		},
+		{
+			name:  "without final newline",
+			input: "image:\n  repository: nginx",
+			path:  []string{"image"},
+			want:  "image:\n  repository: nginx\n  tag: \"1.0\"\n",
+		},
+		{
+			name:  "CRLF",
+			input: "image:\r\n  repository: nginx\r\nreplicas: 2\r\n",
+			path:  []string{"image"},
+			want:  "image:\r\n  repository: nginx\r\n  tag: \"1.0\"\r\nreplicas: 2\r\n",
+		},
+		{
+			name:    "flow mapping",
+			input:   "image: {repository: nginx}\n",
+			path:    []string{"image"},
+			wantErr: "only non-empty block mappings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("values.yaml", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			err = file.InsertKey(Lookup(file.Documents()[0].Content[0], tt.path...), "tag", "1.0")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("InsertKey error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InsertKey: %v", err)
+			}
+			if string(file.Data) != tt.want {
+				t.Errorf("file =\n%q\nwant\n%q", file.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "middle entry",
+			input: "image:\n  repository: nginx\n  digest: sha256:abc # pinned\n  tag: \"1.0\"\n",
+			want:  "image:\n  repository: nginx\n  tag: \"1.0\"\n",
+		},
+		{
+			name:  "block scalar value",
+			input: "image:\n  digest: |\n    sha256:abc\n\n    more\n  tag: \"1.0\"\n",
+			want:  "image:\n  tag: \"1.0\"\n",
+		},
+		{
+			name:  "CRLF",
+			input: "image:\r\n  digest: sha256:abc\r\n  tag: \"1.0\"\r\n",
+			want:  "image:\r\n  tag: \"1.0\"\r\n",
+		},
+		{
+			name:  "missing key",
+			input: "image:\n  tag: \"1.0\"\n",
+			want:  "image:\n  tag: \"1.0\"\n",
+		},
+		{
+			name:  "first document of several",
+			input: "image:\n  digest: sha256:abc\n  tag: \"1.0\"\n---\nimage:\n  digest: sha256:def\n",
+			want:  "image:\n  tag: \"1.0\"\n---\nimage:\n  digest: sha256:def\n",
+		},
+		{
+			name:    "flow mapping",
+			input:   "image: {digest: sha256:abc, tag: \"1.0\"}\n",
+			wantErr: "does not start its own line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("values.yaml", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			err = file.DeleteKey(Lookup(file.Documents()[0].Content[0], "image"), "digest")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DeleteKey error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteKey: %v", err)
+			}
+			if string(file.Data) != tt.want {
+				t.Errorf("file =\n%q\nwant\n%q", file.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendItem(t *testing.T) {
+	item := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "name"}, {Kind: yaml.ScalarNode, Value: "b"},
+	}}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "indented sequence",
+			input: "images:\n  - name: a # first\n    newTag: v1\nresources: []\n",
+			want:  "images:\n  - name: a # first\n    newTag: v1\n  - name: b\nresources: []\n",
+		},
+		{
+			name:  "compact sequence with CRLF",
+			input: "images:\r\n- name: a\r\nresources: []\r\n",
+			want:  "images:\r\n- name: a\r\n- name: b\r\nresources: []\r\n",
+		},
+		{
+			name:    "flow sequence",
+			input:   "images: [{name: a}]\n",
+			wantErr: "only non-empty block sequences",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("kustomization.yaml", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			err = file.AppendItem(Lookup(file.Documents()[0].Content[0], "images"), item)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("AppendItem error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AppendItem: %v", err)
+			}
+			if string(file.Data) != tt.want {
+				t.Errorf("file =\n%q\nwant\n%q", file.Data, tt.want)
+			}
+		})
+	}
+}
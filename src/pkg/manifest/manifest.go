@@ -0,0 +1,368 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// File is a manifest file holding one or more YAML or JSON documents
+type File struct {
+	// Path is the file the manifests were read from, "-" for stdin
+	Path string
+	Data []byte
+	docs []*yaml.Node
+}
+
+// Workload is a workload defined in a manifest file
+type Workload struct {
+	File      *File
+	Kind      string
+	Name      string
+	Namespace string
+	// Labels and Annotations are the metadata of the workload
+	Labels      map[string]string
+	Annotations map[string]string
+	// PodSpec is the decoded pod spec of the workload
+	PodSpec *corev1.PodSpec
+
+	// images holds the image nodes of the containers, by container name
+	images map[string]*yaml.Node
+}
+
+// podSpecPaths lists where the pod spec of each supported kind lives
+var podSpecPaths = map[string][]string{
+	"Deployment": {"spec", "template", "spec"},
+	"Pod":        {"spec"},
+}
+
+// manifestExtensions lists the file extensions read from directories
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// Load reads manifest files. Directories are read for .yaml, .yml and .json files,
+// including subdirectories when recursive is set, and "-" reads from stdin.
+func Load(paths []string, recursive bool, stdin io.Reader) ([]*File, error) {
+	var files []*File
+	for _, path := range paths {
+		if path == "-" {
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifests from stdin: %w", err)
+			}
+			file, err := Parse(path, data)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests: %w", err)
+		}
+		if !info.IsDir() {
+			file, err := ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(name string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if name != path && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !manifestExtensions[strings.ToLower(filepath.Ext(name))] {
+				return nil
+			}
+			file, err := ReadFile(name)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// ReadFile reads and parses a single manifest file
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	return Parse(path, data)
+}
+
+// Parse parses the YAML or JSON documents of a manifest file
+func Parse(path string, data []byte) (*File, error) {
+	file := &File{Path: path}
+	if err := file.parse(data); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// parse replaces the content of the file and parses its documents
+func (f *File) parse(data []byte) error {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := &yaml.Node{}
+		if err := decoder.Decode(doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to parse manifest %s: %w", f.Path, err)
+		}
+		docs = append(docs, doc)
+	}
+	f.Data = data
+	f.docs = docs
+	return nil
+}
+
+// Save writes the file back to its path, or to stdout when it was read from stdin
+func (f *File) Save(stdout io.Writer) error {
+	if f.Path == "-" {
+		_, err := stdout.Write(f.Data)
+		return err
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", f.Path, err)
+	}
+	if err := os.WriteFile(f.Path, f.Data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// Workloads returns the supported workloads of the file, including the items of List documents
+func (f *File) Workloads() ([]*Workload, error) {
+	var workloads []*Workload
+	for _, doc := range f.docs {
+		if len(doc.Content) == 0 {
+			continue
+		}
+		found, err := f.collect(doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		workloads = append(workloads, found...)
+	}
+	return workloads, nil
+}
+
+// collect returns the workload defined by an object node, or the workloads of a List
+func (f *File) collect(object *yaml.Node) ([]*Workload, error) {
+	if object.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	kind := scalar(Lookup(object, "kind"))
+	if strings.HasSuffix(kind, "List") {
+		items := Lookup(object, "items")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			return nil, nil
+		}
+		var workloads []*Workload
+		for _, item := range items.Content {
+			found, err := f.collect(item)
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, found...)
+		}
+		return workloads, nil
+	}
+
+	path, supported := podSpecPaths[kind]
+	if !supported {
+		return nil, nil
+	}
+
+	workload := &Workload{
+		File:      f,
+		Kind:      kind,
+		Name:      scalar(Lookup(object, "metadata", "name")),
+		Namespace: scalar(Lookup(object, "metadata", "namespace")),
+		PodSpec:   &corev1.PodSpec{},
+		images:    make(map[string]*yaml.Node),
+	}
+
+	metadata := []struct {
+		key string
+		out *map[string]string
+	}{
+		{"labels", &workload.Labels},
+		{"annotations", &workload.Annotations},
+	}
+	for _, field := range metadata {
+		if node := Lookup(object, "metadata", field.key); node != nil {
+			if err := decode(node, field.out); err != nil {
+				return nil, fmt.Errorf("%s: invalid metadata.%s of %s %s: %w", f.Path, field.key, kind, workload.Name, err)
+			}
+		}
+	}
+
+	spec := Lookup(object, path...)
+	if spec == nil {
+		return []*Workload{workload}, nil
+	}
+	if err := decode(spec, workload.PodSpec); err != nil {
+		return nil, fmt.Errorf("%s: invalid pod spec of %s %s: %w", f.Path, kind, workload.Name, err)
+	}
+	if containers := Lookup(spec, "containers"); containers != nil && containers.Kind == yaml.SequenceNode {
+		for _, container := range containers.Content {
+			if image := Lookup(container, "image"); image != nil {
+				workload.images[scalar(Lookup(container, "name"))] = image
+			}
+		}
+	}
+	return []*Workload{workload}, nil
+}
+
+// Documents returns the parsed documents of the file
+func (f *File) Documents() []*yaml.Node {
+	return f.docs
+}
+
+// Images returns the container and init container images of all objects in the file, in order of appearance
+func (f *File) Images() []string {
+	var images []string
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i].Value, node.Content[i+1]
+				if (key == "containers" || key == "initContainers") && value.Kind == yaml.SequenceNode {
+					for _, container := range value.Content {
+						if image := scalar(Lookup(container, "image")); image != "" {
+							images = append(images, image)
+						}
+					}
+					continue
+				}
+				walk(value)
+			}
+			return
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	for _, doc := range f.docs {
+		walk(doc)
+	}
+	return images
+}
+
+// Find returns the workload of the given kind and name. It is an error when
+// the workload is missing or defined more than once.
+func Find(files []*File, kind, name string) (*Workload, error) {
+	var matches []*Workload
+	for _, file := range files {
+		workloads, err := file.Workloads()
+		if err != nil {
+			return nil, err
+		}
+		for _, workload := range workloads {
+			if workload.Kind == kind && workload.Name == name {
+				matches = append(matches, workload)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s %s not found in the given manifests", strings.ToLower(kind), name)
+	case 1:
+		return matches[0], nil
+	default:
+		locations := make([]string, 0, len(matches))
+		for _, match := range matches {
+			location := match.File.Path
+			if match.Namespace != "" {
+				location += " (namespace " + match.Namespace + ")"
+			}
+			locations = append(locations, location)
+		}
+		return nil, fmt.Errorf("%s %s is defined %d times: %s", strings.ToLower(kind), name, len(matches), strings.Join(locations, ", "))
+	}
+}
+
+// SetImage rewrites the image of a container in the file. Only the image value is replaced,
+// so comments and formatting are kept. The workload must not be used after the change,
+// find it again to make further changes.
+func (w *Workload) SetImage(container, image string) error {
+	node, exists := w.images[container]
+	if !exists {
+		return fmt.Errorf("%s: container %s of %s %s has no image", w.File.Path, container, strings.ToLower(w.Kind), w.Name)
+	}
+	return w.File.SetScalar(node, image)
+}
+
+// Lookup returns the node at a path of mapping keys, nil if it does not exist
+func Lookup(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// scalar returns the value of a scalar node, "" for other nodes
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// decode converts a node into a Kubernetes API type, which only carries JSON field names
+func decode(node *yaml.Node, out interface{}) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,290 @@
+package outdated
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// defaultConcurrency is the number of parallel registry lookups
+	defaultConcurrency = 8
+	// defaultRegistryQPS limits the registry lookups per second across all registries
+	defaultRegistryQPS = 10
+)
+
+// Checker compares the images running in a cluster against the tags in their registries
+type Checker struct {
+	options *types.Options
+	limiter flowcontrol.RateLimiter
+
+	mu sync.Mutex
+	// repositories caches the tags of each repository, shared by all workloads using it
+	repositories map[string]*repository
+	// clients holds one registry client per set of pull credentials
+	clients map[string]*registry.Client
+}
+
+// repository is a cache entry, its lock makes concurrent lookups of the same repository wait for the first one
+type repository struct {
+	mu   sync.Mutex
+	tags []string
+	done bool
+}
+
+// Report is the result of an outdated check
+type Report struct {
+	Images []Image `json:"images"`
+	// Outdated counts the images with a newer version available
+	Outdated int `json:"outdated"`
+	// Errors counts the images whose registry lookup failed
+	Errors int `json:"errors"`
+}
+
+// Image describes the versions available for the image of a single container
+type Image struct {
+	Namespace  string `json:"namespace"`
+	Deployment string `json:"deployment"`
+	Container  string `json:"container"`
+	Repository string `json:"repository"`
+	Current    string `json:"current"`
+
+	// Patch, Minor and Major are the newest tags of each update level, empty when there is none
+	Patch string `json:"patch,omitempty"`
+	Minor string `json:"minor,omitempty"`
+	Major string `json:"major,omitempty"`
+
+	// Update is the largest update available, empty when the image is up to date
+	Update types.UpdateLevel `json:"update,omitempty"`
+	// Skipped explains why the image was not compared, e.g. a tag that is not a semantic version
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// New creates a new Checker
+func New(options *types.Options) *Checker {
+	qps := options.RegistryQPS
+	if qps <= 0 {
+		qps = defaultRegistryQPS
+	}
+	burst := int(qps)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Checker{
+		options:      options,
+		limiter:      flowcontrol.NewTokenBucketRateLimiter(float32(qps), burst),
+		repositories: make(map[string]*repository),
+		clients:      make(map[string]*registry.Client),
+	}
+}
+
+// Check scans the deployments of the namespace, or of all namespaces, and compares their images
+func (c *Checker) Check(ctx context.Context) (*Report, error) {
+	namespace := c.options.Namespace
+	if c.options.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	deploymentList, err := c.options.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	deployments := deploymentList.Items
+	sort.Slice(deployments, func(i, j int) bool {
+		if deployments[i].Namespace != deployments[j].Namespace {
+			return deployments[i].Namespace < deployments[j].Namespace
+		}
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	report := &Report{}
+	type job struct {
+		deployment *appsv1.Deployment
+		index      int
+	}
+	var jobs []job
+	for i := range deployments {
+		for _, container := range deployments[i].Spec.Template.Spec.Containers {
+			jobs = append(jobs, job{deployment: &deployments[i], index: len(report.Images)})
+			report.Images = append(report.Images, Image{
+				Namespace:  deployments[i].Namespace,
+				Deployment: deployments[i].Name,
+				Container:  container.Name,
+				Repository: container.Image,
+				Current:    getter.ExtractTag(container.Image),
+			})
+		}
+	}
+
+	concurrency := c.options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			image := &report.Images[j.index]
+			c.check(ctx, j.deployment, image)
+		}(j)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, image := range report.Images {
+		if image.Update != "" {
+			report.Outdated++
+		}
+		if image.Error != "" {
+			report.Errors++
+		}
+	}
+	return report, nil
+}
+
+// check fills in the newer versions of a single container image
+func (c *Checker) check(ctx context.Context, deployment *appsv1.Deployment, image *Image) {
+	ref, err := reference.Parse(image.Repository)
+	if err != nil {
+		image.Error = err.Error()
+		return
+	}
+	image.Repository = ref.Name()
+
+	current, err := semver.Parse(image.Current)
+	if err != nil {
+		image.Skipped = "tag is not a semantic version"
+		return
+	}
+
+	names, err := c.listTags(ctx, deployment, ref)
+	if err != nil {
+		image.Error = err.Error()
+		return
+	}
+
+	patch, minor, major := Newest(current, names)
+	if patch != nil {
+		image.Patch = patch.Original
+		image.Update = types.UpdatePatch
+	}
+	if minor != nil {
+		image.Minor = minor.Original
+		image.Update = types.UpdateMinor
+	}
+	if major != nil {
+		image.Major = major.Original
+		image.Update = types.UpdateMajor
+	}
+}
+
+// listTags returns the tags of a repository, looking each repository up only once.
+// Failed lookups are not cached, so a later workload with other pull secrets may succeed.
+func (c *Checker) listTags(ctx context.Context, deployment *appsv1.Deployment, ref *reference.Reference) ([]string, error) {
+	c.mu.Lock()
+	repo, exists := c.repositories[ref.Name()]
+	if !exists {
+		repo = &repository{}
+		c.repositories[ref.Name()] = repo
+	}
+	c.mu.Unlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.done {
+		return repo.tags, nil
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	names, err := c.client(deployment).ListTags(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", ref.Name(), err)
+	}
+	repo.tags, repo.done = names, true
+	return names, nil
+}
+
+// client returns the registry client using the pull secrets of the deployment
+func (c *Checker) client(deployment *appsv1.Deployment) *registry.Client {
+	podSpec := &deployment.Spec.Template.Spec
+	secrets := make([]string, 0, len(podSpec.ImagePullSecrets))
+	for _, secret := range podSpec.ImagePullSecrets {
+		secrets = append(secrets, secret.Name)
+	}
+	key := deployment.Namespace + "|" + podSpec.ServiceAccountName + "|" + strings.Join(secrets, ",")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, exists := c.clients[key]; exists {
+		return client
+	}
+
+	// Each client reads pull secrets from the namespace of its workloads
+	options := *c.options
+	options.Namespace = deployment.Namespace
+	client := registry.New(&options)
+	client.UsePodSpecPullSecrets(podSpec)
+	c.clients[key] = client
+	return client
+}
+
+// Newest returns the newest tag of each update level that is newer than current, or nil when there is none.
+// Only tags written like the current one are considered: the same prefix, variant suffix and
+// number of components, so "v1.25" is compared against "v1.26" and "1.25.3-alpine" against "1.26.0-alpine".
+// Pre-releases are ignored.
+func Newest(current *semver.Version, names []string) (patch, minor, major *semver.Version) {
+	for _, name := range names {
+		version, err := semver.Parse(name)
+		if err != nil || version.IsPreRelease() || !sameScheme(version, current) || version.Compare(current) <= 0 {
+			continue
+		}
+
+		switch {
+		case version.Major != current.Major:
+			if major == nil || version.Compare(major) > 0 {
+				major = version
+			}
+		case version.Minor != current.Minor:
+			if minor == nil || version.Compare(minor) > 0 {
+				minor = version
+			}
+		default:
+			if patch == nil || version.Compare(patch) > 0 {
+				patch = version
+			}
+		}
+	}
+	return patch, minor, major
+}
+
+// sameScheme reports whether two versions are written the same way
+func sameScheme(a, b *semver.Version) bool {
+	return a.Prefix == b.Prefix && a.Suffix() == b.Suffix() && a.Components() == b.Components()
+}
@@ -0,0 +1,243 @@
+package plan
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// APIVersion is the version of the rollout plan format
+	APIVersion = "kubectl-image/v1"
+	// Kind identifies rollout plans
+	Kind = "RolloutPlan"
+)
+
+// Action is what happens when a stage fails
+type Action string
+
+const (
+	// ActionHalt stops the plan and leaves the failed stage as it is
+	ActionHalt Action = "halt"
+	// ActionRollback sets the previous images of the failed stage's targets again, then stops the plan
+	ActionRollback Action = "rollback"
+)
+
+// ValidActions returns a list of supported failure actions
+var ValidActions = map[string]Action{
+	"halt":     ActionHalt,
+	"rollback": ActionRollback,
+}
+
+// Plan is a staged rollout of one image to many deployments
+type Plan struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+
+	// Image is the full image to set, Tag only changes the tag of each target's current image
+	Image string `yaml:"image"`
+	Tag   string `yaml:"tag"`
+
+	// Wait, Soak and OnFailure are the defaults of stages that do not set them
+	Wait      Wait          `yaml:"wait"`
+	Soak      time.Duration `yaml:"soak"`
+	OnFailure Action        `yaml:"onFailure"`
+
+	Stages []Stage `yaml:"stages"`
+}
+
+// Wait decides when the rollout of a target is done, like --wait-for and --timeout of set
+type Wait struct {
+	For     string        `yaml:"for"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Stage is a group of targets updated at the same time
+type Stage struct {
+	Name    string   `yaml:"name"`
+	Targets []Target `yaml:"targets"`
+
+	Wait *Wait `yaml:"wait"`
+	// Soak is how long the targets must stay healthy before the next stage starts
+	Soak      *time.Duration `yaml:"soak"`
+	OnFailure Action         `yaml:"onFailure"`
+}
+
+// Target is a deployment in a kubeconfig context
+type Target struct {
+	// Context and Namespace default to the current context and the namespace of the context
+	Context   string `yaml:"context"`
+	Namespace string `yaml:"namespace"`
+	// Resource is "deployment/NAME" or just the deployment name
+	Resource  string `yaml:"resource"`
+	Container string `yaml:"container"`
+}
+
+// Load reads and checks a rollout plan
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollout plan %s: %w", path, err)
+	}
+
+	plan := &Plan{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(plan); err != nil {
+		return nil, fmt.Errorf("failed to parse rollout plan %s: %w", path, err)
+	}
+	if err := plan.validate(); err != nil {
+		return nil, fmt.Errorf("rollout plan %s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// validate checks the plan and fills in the defaults
+func (p *Plan) validate() error {
+	if p.Kind != Kind {
+		return fmt.Errorf("not a rollout plan (kind %q, expected %s)", p.Kind, Kind)
+	}
+	if p.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported version %q (expected %s)", p.APIVersion, APIVersion)
+	}
+	if (p.Image == "") == (p.Tag == "") {
+		return fmt.Errorf("exactly one of image and tag is required")
+	}
+	if len(p.Stages) == 0 {
+		return fmt.Errorf("no stages defined")
+	}
+
+	if p.OnFailure == "" {
+		p.OnFailure = ActionHalt
+	}
+	if err := validateWait(p.Wait, "wait"); err != nil {
+		return err
+	}
+	if _, exists := ValidActions[string(p.OnFailure)]; !exists {
+		return fmt.Errorf("unsupported onFailure %q (must be halt or rollback)", p.OnFailure)
+	}
+
+	names := make(map[string]bool, len(p.Stages))
+	for i := range p.Stages {
+		stage := &p.Stages[i]
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage-%d", i+1)
+		}
+		if names[stage.Name] {
+			return fmt.Errorf("stage %s is defined twice", stage.Name)
+		}
+		names[stage.Name] = true
+
+		if len(stage.Targets) == 0 {
+			return fmt.Errorf("stage %s has no targets", stage.Name)
+		}
+		if stage.Wait != nil {
+			if err := validateWait(*stage.Wait, "stage "+stage.Name+" wait"); err != nil {
+				return err
+			}
+		}
+		if stage.OnFailure != "" {
+			if _, exists := ValidActions[string(stage.OnFailure)]; !exists {
+				return fmt.Errorf("stage %s: unsupported onFailure %q (must be halt or rollback)", stage.Name, stage.OnFailure)
+			}
+		}
+
+		keys := make(map[string]bool, len(stage.Targets))
+		for _, target := range stage.Targets {
+			if _, err := target.DeploymentName(); err != nil {
+				return fmt.Errorf("stage %s: %w", stage.Name, err)
+			}
+			if keys[target.Key()] {
+				return fmt.Errorf("stage %s lists %s twice", stage.Name, target.Key())
+			}
+			keys[target.Key()] = true
+		}
+	}
+	return nil
+}
+
+// validateWait checks the wait criteria of the plan or a stage
+func validateWait(wait Wait, field string) error {
+	if wait.For != "" {
+		if _, exists := types.ValidWaitConditions[strings.ToLower(wait.For)]; !exists {
+			return fmt.Errorf("%s: unsupported for %q (must be one of ready, available, cleanup)", field, wait.For)
+		}
+	}
+	if wait.Timeout < 0 {
+		return fmt.Errorf("%s: timeout must not be negative", field)
+	}
+	return nil
+}
+
+// StageWait returns the wait criteria of a stage, falling back to the plan's
+func (p *Plan) StageWait(stage *Stage) Wait {
+	wait := p.Wait
+	if stage.Wait != nil {
+		if stage.Wait.For != "" {
+			wait.For = stage.Wait.For
+		}
+		if stage.Wait.Timeout != 0 {
+			wait.Timeout = stage.Wait.Timeout
+		}
+	}
+	if wait.For == "" {
+		wait.For = string(types.WaitForCleanup)
+	}
+	return wait
+}
+
+// StageSoak returns the soak time of a stage, falling back to the plan's
+func (p *Plan) StageSoak(stage *Stage) time.Duration {
+	if stage.Soak != nil {
+		return *stage.Soak
+	}
+	return p.Soak
+}
+
+// StageOnFailure returns the failure action of a stage, falling back to the plan's
+func (p *Plan) StageOnFailure(stage *Stage) Action {
+	if stage.OnFailure != "" {
+		return stage.OnFailure
+	}
+	return p.OnFailure
+}
+
+// DeploymentName returns the name of the target deployment
+func (t Target) DeploymentName() (string, error) {
+	resource := t.Resource
+	if kind, name, found := strings.Cut(resource, "/"); found {
+		resourceType, exists := types.ValidResourceTypes[strings.ToLower(kind)]
+		if !exists || resourceType != types.ResourceTypeDeployment {
+			return "", fmt.Errorf("target %s: only deployments can be rolled out", t.Resource)
+		}
+		resource = name
+	}
+	if resource == "" {
+		return "", fmt.Errorf("a target has no resource")
+	}
+	return resource, nil
+}
+
+// Key identifies the target in messages and the state file, e.g. "prod-eu/shop/deployment/api"
+func (t Target) Key() string {
+	name, _ := t.DeploymentName()
+	context := t.Context
+	if context == "" {
+		context = "(current)"
+	}
+	namespace := t.Namespace
+	if namespace == "" {
+		namespace = "(default)"
+	}
+	key := context + "/" + namespace + "/deployment/" + name
+	if t.Container != "" {
+		key += ":" + t.Container
+	}
+	return key
+}
@@ -0,0 +1,333 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/access"
+	"github.com/reedchan7/kubectl-image/src/pkg/client"
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/setter"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// Runner carries out a rollout plan stage by stage, recording its progress in the state
+type Runner struct {
+	plan    *Plan
+	state   *State
+	options *types.Options
+	cfg     *config.Config
+	out     io.Writer
+	icons   *rollout.Printer
+}
+
+// NewRunner creates a Runner. Options supplies the flags shared by every target, such as
+// DryRun, Yes and NoEmoji, and cfg the policies they are checked against; progress is printed to out.
+func NewRunner(plan *Plan, state *State, options *types.Options, cfg *config.Config, out io.Writer) *Runner {
+	return &Runner{
+		plan:    plan,
+		state:   state,
+		options: options,
+		cfg:     cfg,
+		out:     out,
+		icons:   rollout.NewPrinter(options),
+	}
+}
+
+// targetResult is the outcome of setting the image of one target
+type targetResult struct {
+	target Target
+	result *setter.Result
+	err    error
+}
+
+// Run runs the stages that have not completed yet, in order. A failed stage is halted or
+// rolled back as the plan says, and stops the plan with an error.
+func (r *Runner) Run(ctx context.Context) error {
+	for i := range r.plan.Stages {
+		stage := &r.plan.Stages[i]
+		stageState := r.state.Stage(stage.Name)
+		if stageState.Status == StageCompleted && !r.options.DryRun {
+			fmt.Fprintf(r.out, "%s Stage %s (%d/%d) already completed\n", r.icons.Icon("⏭️ ", "[SKIP]"), stage.Name, i+1, len(r.plan.Stages))
+			continue
+		}
+
+		fmt.Fprintf(r.out, "\n==> Stage %s (%d/%d): %d target(s)\n", stage.Name, i+1, len(r.plan.Stages), len(stage.Targets))
+		if err := r.runStage(ctx, stage, stageState); err != nil {
+			return err
+		}
+	}
+
+	if !r.options.DryRun {
+		fmt.Fprintf(r.out, "\n%s Rollout plan completed\n", r.icons.Icon("🎉", "[DONE]"))
+	}
+	return nil
+}
+
+// runStage sets the image of the stage's targets, waits for their rollouts and soaks
+func (r *Runner) runStage(ctx context.Context, stage *Stage, stageState *StageState) error {
+	stageState.Status = StageRunning
+	stageState.Finished = nil
+	if err := r.save(); err != nil {
+		return err
+	}
+
+	wait := r.plan.StageWait(stage)
+	results := r.setTargets(ctx, stage.Targets, wait, func(target Target) (string, string) {
+		return r.plan.Image, r.plan.Tag
+	})
+
+	failed := 0
+	for _, outcome := range results {
+		key := outcome.target.Key()
+		if outcome.result != nil && !r.options.DryRun {
+			r.recordTarget(stageState, key, outcome.result, outcome.err)
+		}
+		switch {
+		case outcome.err != nil:
+			failed++
+			fmt.Fprintf(r.out, "  %s %s: %v\n", r.icons.Icon("❌", "[FAIL]"), key, outcome.err)
+		case r.options.DryRun:
+			fmt.Fprintf(r.out, "  %s %s: %s -> %s (dry-run)\n", r.icons.Icon("🔍", "[DRY-RUN]"), key, outcome.result.OldImage, outcome.result.NewImage)
+		default:
+			fmt.Fprintf(r.out, "  %s %s: %s -> %s\n", r.icons.Icon("✅", "[OK]"), key, outcome.result.OldImage, outcome.result.NewImage)
+		}
+	}
+	if r.options.DryRun {
+		return nil
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("rollout plan interrupted during stage %s, run it again to resume", stage.Name)
+	}
+	if failed > 0 {
+		return r.fail(ctx, stage, stageState, fmt.Sprintf("%d of %d targets failed", failed, len(stage.Targets)))
+	}
+
+	if soak := r.plan.StageSoak(stage); soak > 0 {
+		stageState.Status = StageSoaking
+		if err := r.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "  %s Soaking for %s\n", r.icons.Icon("⏳", "[SOAK]"), soak)
+
+		timer := time.NewTimer(soak)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("rollout plan interrupted while soaking stage %s, run it again to resume", stage.Name)
+		case <-timer.C:
+		}
+
+		if problems := r.checkHealth(ctx, stage, stageState); len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintf(r.out, "  %s %s\n", r.icons.Icon("❌", "[FAIL]"), problem)
+			}
+			return r.fail(ctx, stage, stageState, fmt.Sprintf("%d of %d targets unhealthy after soaking", len(problems), len(stage.Targets)))
+		}
+	}
+
+	now := time.Now().UTC()
+	stageState.Status = StageCompleted
+	stageState.Finished = &now
+	fmt.Fprintf(r.out, "  %s Stage %s completed\n", r.icons.Icon("✅", "[OK]"), stage.Name)
+	return r.save()
+}
+
+// setTargets sets the image of every target at the same time, waiting for the rollouts.
+// images returns the image or tag to set for a target.
+func (r *Runner) setTargets(ctx context.Context, targets []Target, wait Wait, images func(Target) (string, string)) []targetResult {
+	results := make([]targetResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i].target = target
+		wg.Add(1)
+		go func(outcome *targetResult) {
+			defer wg.Done()
+			image, tag := images(target)
+			outcome.result, outcome.err = r.setTarget(ctx, target, wait, image, tag)
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// setTarget sets the image of one target through the setter, waiting for its rollout
+func (r *Runner) setTarget(ctx context.Context, target Target, wait Wait, image, tag string) (*setter.Result, error) {
+	options, err := r.targetOptions(target)
+	if err != nil {
+		return nil, err
+	}
+	options.Image = image
+	options.Tag = tag
+	options.Wait = true
+	options.WaitFor = wait.For
+	options.Timeout = wait.Timeout
+
+	if err := access.Check(ctx, options.Clientset, options.Namespace, access.Set(options, r.cfg)); err != nil {
+		return nil, err
+	}
+	return setter.New(options, r.cfg).Set(ctx)
+}
+
+// targetOptions returns options connected to the target's cluster and deployment.
+// Targets are updated in parallel, so their progress is not printed and protected
+// ones cannot be prompted for, they need Yes.
+func (r *Runner) targetOptions(target Target) (*types.Options, error) {
+	name, err := target.DeploymentName()
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := client.NewCluster(target.Context, target.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &types.Options{
+		ResourceType:  string(types.ResourceTypeDeployment),
+		ResourceName:  name,
+		ContainerName: target.Container,
+		Namespace:     cluster.Namespace,
+		Context:       cluster.KubeContext(),
+		Clientset:     cluster.Clientset,
+		PollInterval:  5 * time.Second,
+		CleanupGrace:  60 * time.Second,
+		DryRun:        r.options.DryRun,
+		Yes:           r.options.Yes,
+		NoEmoji:       r.options.NoEmoji,
+	}
+	return options, nil
+}
+
+// recordTarget records the outcome of a target, keeping the image it had before the plan
+// first changed it, so resuming a stage still rolls back to the original image
+func (r *Runner) recordTarget(stageState *StageState, key string, result *setter.Result, err error) {
+	targetState := stageState.Target(key)
+	if targetState == nil {
+		stageState.Targets = append(stageState.Targets, TargetState{Target: key, OldImage: result.OldImage})
+		targetState = &stageState.Targets[len(stageState.Targets)-1]
+	}
+	if targetState.OldImage == "" {
+		targetState.OldImage = result.OldImage
+	}
+	targetState.Container = result.Container
+	targetState.NewImage = result.NewImage
+	targetState.Status = TargetUpdated
+	targetState.Error = ""
+	if err != nil {
+		targetState.Status = TargetFailed
+		targetState.Error = err.Error()
+	}
+}
+
+// checkHealth checks that the targets of a stage still run the new image with every pod
+// available after soaking, and returns a message for each one that does not
+func (r *Runner) checkHealth(ctx context.Context, stage *Stage, stageState *StageState) []string {
+	var problems []string
+	for _, target := range stage.Targets {
+		key := target.Key()
+		options, err := r.targetOptions(target)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		status, err := rollout.New(options).Status(ctx)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+
+		if targetState := stageState.Target(key); targetState != nil && targetState.NewImage != "" {
+			images := make([]string, 0, len(status.TargetImages))
+			for _, image := range status.TargetImages {
+				images = append(images, image.Image)
+			}
+			if !slices.Contains(images, targetState.NewImage) {
+				problems = append(problems, fmt.Sprintf("%s: no longer runs %s", key, targetState.NewImage))
+				continue
+			}
+		}
+		switch {
+		case len(status.StuckPods) > 0:
+			problems = append(problems, fmt.Sprintf("%s: %d pod(s) not ready, e.g. %s is %s", key, len(status.StuckPods), status.StuckPods[0].Name, status.StuckPods[0].Status))
+		case status.Available < status.Desired:
+			problems = append(problems, fmt.Sprintf("%s: %d of %d pods available", key, status.Available, status.Desired))
+		}
+	}
+	return problems
+}
+
+// fail halts the plan at a failed stage, first setting the previous images again when
+// the stage rolls back
+func (r *Runner) fail(ctx context.Context, stage *Stage, stageState *StageState, reason string) error {
+	now := time.Now().UTC()
+	stageState.Finished = &now
+	stageState.Status = StageFailed
+
+	if r.plan.StageOnFailure(stage) != ActionRollback {
+		if err := r.save(); err != nil {
+			return err
+		}
+		return fmt.Errorf("stage %s failed: %s, rollout plan halted", stage.Name, reason)
+	}
+
+	var targets []Target
+	for _, target := range stage.Targets {
+		targetState := stageState.Target(target.Key())
+		if targetState != nil && targetState.OldImage != "" && targetState.OldImage != targetState.NewImage {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		if err := r.save(); err != nil {
+			return err
+		}
+		return fmt.Errorf("stage %s failed: %s, nothing to roll back", stage.Name, reason)
+	}
+
+	fmt.Fprintf(r.out, "  %s Rolling back stage %s\n", r.icons.Icon("↩️ ", "[ROLLBACK]"), stage.Name)
+	results := r.setTargets(ctx, targets, r.plan.StageWait(stage), func(target Target) (string, string) {
+		return stageState.Target(target.Key()).OldImage, ""
+	})
+
+	failed := 0
+	for _, outcome := range results {
+		key := outcome.target.Key()
+		targetState := stageState.Target(key)
+		if outcome.err != nil {
+			failed++
+			targetState.Error = "rollback: " + outcome.err.Error()
+			fmt.Fprintf(r.out, "  %s %s: rollback failed: %v\n", r.icons.Icon("❌", "[FAIL]"), key, outcome.err)
+			continue
+		}
+		targetState.Status = TargetRolledBack
+		targetState.Error = ""
+		fmt.Fprintf(r.out, "  %s %s: rolled back to %s\n", r.icons.Icon("↩️ ", "[ROLLBACK]"), key, targetState.OldImage)
+	}
+
+	if failed == 0 {
+		stageState.Status = StageRolledBack
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("stage %s failed: %s, and %d rollback(s) failed", stage.Name, reason, failed)
+	}
+	return fmt.Errorf("stage %s failed: %s, rolled back", stage.Name, reason)
+}
+
+// save writes the state, except for dry runs which change nothing
+func (r *Runner) save() error {
+	if r.options.DryRun {
+		return nil
+	}
+	return r.state.Save()
+}
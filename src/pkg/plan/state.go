@@ -0,0 +1,139 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StageStatus is the progress of a stage
+type StageStatus string
+
+const (
+	StageRunning    StageStatus = "running"
+	StageSoaking    StageStatus = "soaking"
+	StageCompleted  StageStatus = "completed"
+	StageFailed     StageStatus = "failed"
+	StageRolledBack StageStatus = "rolled-back"
+)
+
+// TargetStatus is the progress of a target
+type TargetStatus string
+
+const (
+	TargetUpdated    TargetStatus = "updated"
+	TargetFailed     TargetStatus = "failed"
+	TargetRolledBack TargetStatus = "rolled-back"
+)
+
+// State is the progress of a plan, saved after every step so an interrupted plan can be resumed
+type State struct {
+	// Image is the image or tag of the plan, a plan for another image cannot resume the state
+	Image  string       `yaml:"image"`
+	Stages []StageState `yaml:"stages"`
+
+	path string
+}
+
+// StageState is the progress of a stage
+type StageState struct {
+	Name    string        `yaml:"name"`
+	Status  StageStatus   `yaml:"status"`
+	Targets []TargetState `yaml:"targets,omitempty"`
+	// Finished is when the stage completed or failed
+	Finished *time.Time `yaml:"finished,omitempty"`
+}
+
+// TargetState is the progress of a target. OldImage is the image before the plan
+// changed it, which a rollback sets again.
+type TargetState struct {
+	Target    string       `yaml:"target"`
+	Container string       `yaml:"container,omitempty"`
+	OldImage  string       `yaml:"oldImage,omitempty"`
+	NewImage  string       `yaml:"newImage,omitempty"`
+	Status    TargetStatus `yaml:"status"`
+	Error     string       `yaml:"error,omitempty"`
+}
+
+// StatePath returns the default state file of a plan, next to it, e.g. plan.state.yaml for plan.yaml
+func StatePath(planPath string) string {
+	ext := filepath.Ext(planPath)
+	return strings.TrimSuffix(planPath, ext) + ".state.yaml"
+}
+
+// NewState returns the state of a plan that has not started, saved to path
+func NewState(path string, plan *Plan) *State {
+	return &State{Image: planImage(plan), path: path}
+}
+
+// LoadState reads the state of a plan, a missing file is a plan that has not started
+func LoadState(path string, plan *Plan) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewState(path, plan), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollout state %s: %w", path, err)
+	}
+
+	state := &State{}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse rollout state %s: %w", path, err)
+	}
+	if image := planImage(plan); state.Image != image {
+		return nil, fmt.Errorf("rollout state %s belongs to a rollout of %s, not %s: use --restart to start over", path, state.Image, image)
+	}
+	state.path = path
+	return state, nil
+}
+
+// planImage returns the image or tag a plan sets
+func planImage(plan *Plan) string {
+	if plan.Image != "" {
+		return plan.Image
+	}
+	return plan.Tag
+}
+
+// Save writes the state file, replacing it atomically so an interruption never leaves a partial file
+func (s *State) Save() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rollout state %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write rollout state %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Stage returns the state of a stage, adding it when it has none yet
+func (s *State) Stage(name string) *StageState {
+	for i := range s.Stages {
+		if s.Stages[i].Name == name {
+			return &s.Stages[i]
+		}
+	}
+	s.Stages = append(s.Stages, StageState{Name: name, Status: StageRunning})
+	return &s.Stages[len(s.Stages)-1]
+}
+
+// Target returns the state of a target of the stage, nil when it was not updated yet
+func (s *StageState) Target(key string) *TargetState {
+	for i := range s.Targets {
+		if s.Targets[i].Target == key {
+			return &s.Targets[i]
+		}
+	}
+	return nil
+}
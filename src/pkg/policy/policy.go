@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/glob"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// Policy restricts the images that may be set in the contexts and namespaces it applies to.
+// Repository and tag patterns are globs whose "*" also matches "/".
+type Policy struct {
+	Name string `yaml:"name"`
+
+	// Contexts and Namespaces limit where the policy applies, empty means everywhere
+	Contexts   []string `yaml:"contexts"`
+	Namespaces []string `yaml:"namespaces"`
+
+	// AllowedRepositories and DeniedRepositories match the repository without tag or digest,
+	// both as written and fully qualified, e.g. "nginx" and "docker.io/library/nginx"
+	AllowedRepositories []string `yaml:"allowedRepositories"`
+	DeniedRepositories  []string `yaml:"deniedRepositories"`
+	// RequireDigest refuses images that are not pinned to a digest
+	RequireDigest bool `yaml:"requireDigest"`
+	// ForbiddenTags refuses matching tags, images without tag and digest count as "latest"
+	ForbiddenTags []string `yaml:"forbiddenTags"`
+	// TagPattern is a regular expression every tag must match.
+	// Like the other tag rules it passes images pinned to a digest without tag.
+	TagPattern string `yaml:"tagPattern"`
+	// SemverOnly refuses tags that are not full semantic versions, "v" prefixes are allowed
+	SemverOnly bool `yaml:"semverOnly"`
+	// Rules are CEL expressions for everything the fields above cannot express, checked last
+	Rules []Rule `yaml:"rules"`
+
+	tagPattern *regexp.Regexp
+}
+
+// Target is an image about to be set
+type Target struct {
+	// Context and Namespace are empty when unknown, e.g. for manifests without namespace.
+	// An unknown one may be any, so policies limited to contexts or namespaces apply to it.
+	Context   string `yaml:"context"`
+	Namespace string `yaml:"namespace"`
+	Container string `yaml:"container"`
+	OldImage  string `yaml:"oldImage"`
+	NewImage  string `yaml:"newImage"`
+
+	// Workload and NamespaceLabels are only used by rules
+	Workload        Workload          `yaml:"workload"`
+	NamespaceLabels map[string]string `yaml:"namespaceLabels"`
+}
+
+// Workload is the metadata of the workload whose image is set
+type Workload struct {
+	Kind        string            `yaml:"kind"`
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// Compile checks the policy and prepares its regular expression
+func (p *Policy) Compile() error {
+	if p.Name == "" {
+		return fmt.Errorf("every policy needs a name")
+	}
+	if p.TagPattern != "" {
+		pattern, err := regexp.Compile(p.TagPattern)
+		if err != nil {
+			return fmt.Errorf("policy %s: invalid tagPattern: %w", p.Name, err)
+		}
+		p.tagPattern = pattern
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			return fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// AppliesTo reports whether the policy covers the context and namespace of the target.
+// It fails closed: an unknown context or namespace is covered, so that editing files
+// without namespace cannot bypass a policy of the namespace they are deployed to.
+func (p *Policy) AppliesTo(target Target) bool {
+	if len(p.Contexts) > 0 && target.Context != "" && !glob.MatchAny(p.Contexts, target.Context) {
+		return false
+	}
+	if len(p.Namespaces) > 0 && target.Namespace != "" && !glob.MatchAny(p.Namespaces, target.Namespace) {
+		return false
+	}
+	return true
+}
+
+// Check returns a *types.PolicyViolationError naming the first rule the new image breaks, nil if it complies
+func (p *Policy) Check(target Target) error {
+	ref, err := reference.Parse(target.NewImage)
+	if err != nil {
+		return &types.InvalidReferenceError{Reference: target.NewImage, Reason: err.Error()}
+	}
+	violation := func(rule, format string, args ...interface{}) error {
+		return &types.PolicyViolationError{
+			Policy: p.Name,
+			Rule:   rule,
+			Image:  target.NewImage,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+
+	repositories := []string{ref.Name(), stripReference(target.NewImage)}
+	if len(p.AllowedRepositories) > 0 && !matchAny(p.AllowedRepositories, repositories) {
+		return violation("allowedRepositories", "repository %s is not in %v", ref.Name(), p.AllowedRepositories)
+	}
+	for _, pattern := range p.DeniedRepositories {
+		if matchAny([]string{pattern}, repositories) {
+			return violation("deniedRepositories", "repository %s matches denied %s", ref.Name(), pattern)
+		}
+	}
+
+	if p.RequireDigest && ref.Digest == "" {
+		return violation("requireDigest", "the image must be pinned to a digest, e.g. %s@sha256:...", stripReference(target.NewImage))
+	}
+
+	// Images pinned to a digest alone have no tag to check
+	if tag := ref.TagOrDefault(); tag != "" {
+		for _, pattern := range p.ForbiddenTags {
+			if glob.Match(pattern, tag) {
+				return violation("forbiddenTags", "tag %q is forbidden", tag)
+			}
+		}
+		if p.tagPattern != nil && !p.tagPattern.MatchString(tag) {
+			return violation("tagPattern", "tag %q does not match %s", tag, p.TagPattern)
+		}
+		if p.SemverOnly {
+			if version, err := semver.Parse(tag); err != nil || version.Components() < 3 {
+				return violation("semverOnly", "tag %q is not a semantic version MAJOR.MINOR.PATCH", tag)
+			}
+		}
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		passed, err := rule.evaluate(target)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+		if !passed {
+			if rule.Message == "" {
+				return violation(rule.Name, "%s is false", rule.Expression)
+			}
+			return violation(rule.Name, "%s", rule.Message)
+		}
+	}
+	return nil
+}
+
+// NeedsNamespaceLabels reports whether a policy with rules applies to the target,
+// so the labels of its namespace have to be looked up before evaluating
+func NeedsNamespaceLabels(policies []Policy, target Target) bool {
+	for i := range policies {
+		if len(policies[i].Rules) > 0 && policies[i].AppliesTo(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks the target against every policy that applies to it and returns the first violation
+func Evaluate(policies []Policy, target Target) error {
+	for i := range policies {
+		if !policies[i].AppliesTo(target) {
+			continue
+		}
+		if err := policies[i].Check(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether one of the names matches one of the patterns
+func matchAny(patterns, names []string) bool {
+	for _, name := range names {
+		if glob.MatchAny(patterns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripReference returns the repository of an image as written, without tag or digest
+func stripReference(image string) string {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return image
+	}
+	name := image
+	if ref.Digest != "" {
+		name = name[:len(name)-len(ref.Digest)-1]
+	}
+	if ref.Tag != "" {
+		name = name[:len(name)-len(ref.Tag)-1]
+	}
+	return name
+}
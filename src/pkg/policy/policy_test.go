@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		image    string
+		wantRule string
+	}{
+		{name: "allowed registry glob", policy: Policy{AllowedRepositories: []string{"registry.corp/*"}}, image: "registry.corp/team/app:1.0"},
+		{name: "allowed glob matches nested paths", policy: Policy{AllowedRepositories: []string{"registry.corp/*/app"}}, image: "registry.corp/a/b/app:1.0"},
+		{name: "allowed short name", policy: Policy{AllowedRepositories: []string{"nginx"}}, image: "nginx:1.25"},
+		{name: "allowed fully qualified name", policy: Policy{AllowedRepositories: []string{"docker.io/library/*"}}, image: "nginx:1.25"},
+		{name: "not allowed", policy: Policy{AllowedRepositories: []string{"registry.corp/*"}}, image: "docker.io/team/app:1.0", wantRule: "allowedRepositories"},
+		{name: "question mark matches one character", policy: Policy{AllowedRepositories: []string{"registry.corp/app?"}}, image: "registry.corp/app12:1.0", wantRule: "allowedRepositories"},
+		{name: "glob characters are literal", policy: Policy{AllowedRepositories: []string{"registry.corp/app.v1"}}, image: "registry.corp/appxv1:1.0", wantRule: "allowedRepositories"},
+		{name: "denied", policy: Policy{DeniedRepositories: []string{"*/untrusted/*"}}, image: "registry.corp/untrusted/app:1.0", wantRule: "deniedRepositories"},
+		{name: "digest required", policy: Policy{RequireDigest: true}, image: "nginx:1.25", wantRule: "requireDigest"},
+		{name: "digest given", policy: Policy{RequireDigest: true}, image: "nginx:1.25@sha256:" + strings.Repeat("a", 64)},
+		{name: "forbidden tag", policy: Policy{ForbiddenTags: []string{"*-dev"}}, image: "nginx:1.25-dev", wantRule: "forbiddenTags"},
+		{name: "no tag is latest", policy: Policy{ForbiddenTags: []string{"latest"}}, image: "nginx", wantRule: "forbiddenTags"},
+		{name: "digest alone has no tag", policy: Policy{ForbiddenTags: []string{"latest"}}, image: "nginx@sha256:" + strings.Repeat("a", 64)},
+		{name: "tag pattern", policy: Policy{TagPattern: `^v\d+`}, image: "nginx:1.25", wantRule: "tagPattern"},
+		{name: "semver only", policy: Policy{SemverOnly: true}, image: "nginx:1.25", wantRule: "semverOnly"},
+		{name: "semver with v prefix", policy: Policy{SemverOnly: true}, image: "nginx:v1.25.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.Name = "test"
+			if err := tt.policy.Compile(); err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			assertViolation(t, tt.policy.Check(Target{NewImage: tt.image}), tt.wantRule)
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	target := Target{
+		Context:         "prod-eu",
+		Namespace:       "payments",
+		Container:       "api",
+		OldImage:        "registry.corp/team/api:1.4.0",
+		NewImage:        "registry.corp/team/api:main-1.5.0",
+		Workload:        Workload{Kind: "Deployment", Name: "api", Labels: map[string]string{"branch": "main"}},
+		NamespaceLabels: map[string]string{"env": "prod"},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		wantRule   string
+		wantErr    bool
+	}{
+		{name: "image fields", expression: `new.registry == "registry.corp" && new.repository == "team/api" && new.name == "registry.corp/team/api" && new.tag == "main-1.5.0"`},
+		{name: "old image", expression: `old.tag == "1.4.0"`},
+		{name: "workload labels", expression: `new.tag.startsWith(workload.labels.branch + "-")`},
+		{name: "namespace of the target", expression: `workload.namespace == "payments"`},
+		{name: "namespace labels", expression: `namespaceLabels.env == "prod" && context == "prod-eu" && container == "api"`},
+		{name: "optional key", expression: `!("team" in namespaceLabels) || namespaceLabels.team == "x"`},
+		{name: "false", expression: `new.tag.startsWith("release-")`, wantRule: "rule"},
+		{name: "missing key", expression: `workload.labels.team == "x"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{Name: "test", Rules: []Rule{{Name: "rule", Expression: tt.expression}}}
+			if err := p.Compile(); err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			err := p.Check(target)
+			if tt.wantErr {
+				var violation *types.PolicyViolationError
+				if err == nil || errors.As(err, &violation) {
+					t.Fatalf("check = %v, want an evaluation error", err)
+				}
+				return
+			}
+			assertViolation(t, err, tt.wantRule)
+		})
+	}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	for _, expression := range []string{`new.tag ==`, `new.tag`, `unknown == "x"`} {
+		p := Policy{Name: "test", Rules: []Rule{{Name: "rule", Expression: expression}}}
+		if err := p.Compile(); err == nil {
+			t.Errorf("expression %q compiled, want an error", expression)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		target Target
+		want   bool
+	}{
+		{name: "everywhere", policy: Policy{}, target: Target{Context: "dev", Namespace: "team"}, want: true},
+		{name: "matching namespace", policy: Policy{Namespaces: []string{"pay*"}}, target: Target{Namespace: "payments"}, want: true},
+		{name: "other namespace", policy: Policy{Namespaces: []string{"pay*"}}, target: Target{Namespace: "dev"}},
+		{name: "unknown namespace", policy: Policy{Namespaces: []string{"payments"}}, target: Target{}, want: true},
+		{name: "matching context", policy: Policy{Contexts: []string{"prod-*"}}, target: Target{Context: "prod-eu"}, want: true},
+		{name: "other context", policy: Policy{Contexts: []string{"prod-*"}}, target: Target{Context: "dev"}},
+		{name: "unknown context", policy: Policy{Contexts: []string{"prod-*"}}, target: Target{Namespace: "payments"}, want: true},
+		{name: "context and namespace", policy: Policy{Contexts: []string{"prod-*"}, Namespaces: []string{"payments"}}, target: Target{Context: "prod-eu", Namespace: "dev"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.AppliesTo(tt.target); got != tt.want {
+				t.Errorf("AppliesTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policies := []Policy{
+		{Name: "prod-digest", Namespaces: []string{"prod"}, RequireDigest: true},
+		{Name: "no-latest", ForbiddenTags: []string{"latest"}},
+	}
+	for i := range policies {
+		if err := policies[i].Compile(); err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		target     Target
+		wantPolicy string
+	}{
+		{name: "other namespace", target: Target{Namespace: "dev", NewImage: "nginx:1.25"}},
+		{name: "scoped namespace", target: Target{Namespace: "prod", NewImage: "nginx:1.25"}, wantPolicy: "prod-digest"},
+		{name: "unknown namespace fails closed", target: Target{NewImage: "nginx:1.25"}, wantPolicy: "prod-digest"},
+		{name: "unscoped policy", target: Target{Namespace: "dev", NewImage: "nginx:latest"}, wantPolicy: "no-latest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Evaluate(policies, tt.target)
+			var violation *types.PolicyViolationError
+			switch {
+			case tt.wantPolicy == "" && err != nil:
+				t.Errorf("evaluate = %v, want no violation", err)
+			case tt.wantPolicy != "" && !errors.As(err, &violation):
+				t.Errorf("evaluate = %v, want a violation of %s", err, tt.wantPolicy)
+			case tt.wantPolicy != "" && violation.Policy != tt.wantPolicy:
+				t.Errorf("violated policy = %s, want %s", violation.Policy, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+// assertViolation checks that err breaks the rule, or is nil when rule is empty
+func assertViolation(t *testing.T, err error, rule string) {
+	t.Helper()
+	if rule == "" {
+		if err != nil {
+			t.Errorf("check = %v, want no violation", err)
+		}
+		return
+	}
+	var violation *types.PolicyViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("check = %v, want a violation of %s", err, rule)
+	}
+	if violation.Rule != rule {
+		t.Errorf("broken rule = %s, want %s", violation.Rule, rule)
+	}
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Rule is a CEL expression that must evaluate to true for an image to be set.
+// Expressions see these variables:
+//
+//	old, new         parsed images with image, registry, repository, name, tag and digest
+//	container        container name, empty when unknown
+//	workload         kind, name, namespace, labels and annotations of the workload
+//	namespaceLabels  labels of the target namespace, empty when unknown
+//	context          kubeconfig context, empty when unknown
+//
+// Indexing a missing key is an error that refuses the image, so optional keys are tested with "in", e.g.
+// !("env" in namespaceLabels) || namespaceLabels.env != "dev" || new.tag.startsWith(workload.labels.branch + "-")
+type Rule struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+	// Message explains the refusal, the expression is shown when empty
+	Message string `yaml:"message"`
+
+	program cel.Program
+}
+
+// ruleEnvironment declares the variables rules can use
+var ruleEnvironment = mustRuleEnvironment()
+
+// mustRuleEnvironment creates the environment of rules, which only fails for invalid declarations
+func mustRuleEnvironment() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("old", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("new", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("container", cel.StringType),
+		cel.Variable("workload", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("namespaceLabels", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("context", cel.StringType),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+// compile checks the expression and prepares it for evaluation
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("every rule needs a name")
+	}
+	ast, issues := ruleEnvironment.Compile(r.Expression)
+	if issues != nil && issues.Err() != nil {
+		return fmt.Errorf("rule %s: invalid expression: %w", r.Name, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return fmt.Errorf("rule %s: expression returns %s instead of bool", r.Name, ast.OutputType())
+	}
+	program, err := ruleEnvironment.Program(ast)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	r.program = program
+	return nil
+}
+
+// evaluate reports whether the target satisfies the rule
+func (r *Rule) evaluate(target Target) (bool, error) {
+	workload := target.Workload
+	if workload.Namespace == "" {
+		workload.Namespace = target.Namespace
+	}
+	out, _, err := r.program.Eval(map[string]interface{}{
+		"old":       imageVariable(target.OldImage),
+		"new":       imageVariable(target.NewImage),
+		"container": target.Container,
+		"workload": map[string]interface{}{
+			"kind":        workload.Kind,
+			"name":        workload.Name,
+			"namespace":   workload.Namespace,
+			"labels":      stringMap(workload.Labels),
+			"annotations": stringMap(workload.Annotations),
+		},
+		"namespaceLabels": stringMap(target.NamespaceLabels),
+		"context":         target.Context,
+	})
+	if err != nil {
+		return false, fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	passed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("rule %s: expression returned %v instead of a bool", r.Name, out.Value())
+	}
+	return passed, nil
+}
+
+// imageVariable describes an image to rules. Only image is set when the image is unknown or cannot be parsed.
+// The tag is empty for images pinned to a digest alone and "latest" for images with neither.
+func imageVariable(image string) map[string]string {
+	variable := map[string]string{"image": image, "registry": "", "repository": "", "name": "", "tag": "", "digest": ""}
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return variable
+	}
+	variable["registry"] = ref.Domain
+	variable["repository"] = ref.Path
+	variable["name"] = ref.Name()
+	variable["tag"] = ref.TagOrDefault()
+	variable["digest"] = ref.Digest
+	return variable
+}
+
+// stringMap returns an empty map instead of nil, so rules can index it
+func stringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
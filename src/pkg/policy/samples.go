@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Expected outcomes of a sample
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// Sample is an image update evaluated offline by the policy test command
+type Sample struct {
+	Name   string `yaml:"name"`
+	Target `yaml:",inline"`
+	// Expect is "allow" or "deny", empty when the sample only shows the outcome
+	Expect string `yaml:"expect"`
+}
+
+// Outcome is the result of evaluating a sample
+type Outcome struct {
+	Sample  string `json:"sample"`
+	Image   string `json:"image"`
+	Allowed bool   `json:"allowed"`
+	Expect  string `json:"expect,omitempty"`
+	// Policy and Rule name what refused the image
+	Policy  string `json:"policy,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Error is set when the sample could not be evaluated, e.g. because a rule failed
+	Error string `json:"error,omitempty"`
+	// Passed is false for errors and outcomes other than the expected one
+	Passed bool `json:"passed"`
+}
+
+// LoadSamples reads a YAML list of samples, "-" reads stdin
+func LoadSamples(path string, stdin io.Reader) ([]Sample, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read samples %s: %w", path, err)
+	}
+
+	var samples []Sample
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&samples); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse samples %s: %w", path, err)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("samples %s contain no sample", path)
+	}
+
+	for i := range samples {
+		if samples[i].Name == "" {
+			samples[i].Name = fmt.Sprintf("#%d", i+1)
+		}
+		if samples[i].NewImage == "" {
+			return nil, fmt.Errorf("samples %s: sample %s has no newImage", path, samples[i].Name)
+		}
+		if samples[i].Expect != "" && samples[i].Expect != ExpectAllow && samples[i].Expect != ExpectDeny {
+			return nil, fmt.Errorf("samples %s: sample %s expects %q, must be %s or %s",
+				path, samples[i].Name, samples[i].Expect, ExpectAllow, ExpectDeny)
+		}
+	}
+	return samples, nil
+}
+
+// Test evaluates every sample against the policies
+func Test(policies []Policy, samples []Sample) []Outcome {
+	outcomes := make([]Outcome, 0, len(samples))
+	for _, sample := range samples {
+		outcome := Outcome{Sample: sample.Name, Image: sample.NewImage, Expect: sample.Expect}
+
+		err := Evaluate(policies, sample.Target)
+		var violation *types.PolicyViolationError
+		switch {
+		case err == nil:
+			outcome.Allowed = true
+		case errors.As(err, &violation):
+			outcome.Policy = violation.Policy
+			outcome.Rule = violation.Rule
+			outcome.Message = violation.Reason
+		default:
+			outcome.Error = err.Error()
+		}
+
+		switch {
+		case outcome.Error != "":
+			outcome.Passed = false
+		case sample.Expect == ExpectAllow:
+			outcome.Passed = outcome.Allowed
+		case sample.Expect == ExpectDeny:
+			outcome.Passed = !outcome.Allowed
+		default:
+			outcome.Passed = true
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
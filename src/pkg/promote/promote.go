@@ -0,0 +1,145 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/setter"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// Promoter copies the images of a workload from one namespace or cluster to another
+type Promoter struct {
+	source *types.Options
+	target *types.Options
+	cfg    *config.Config
+}
+
+// Plan describes the image changes a promotion makes on the target
+type Plan struct {
+	Containers []Container
+	// Warnings lists source containers that could not be pinned or do not exist on the target
+	Warnings []string
+}
+
+// Container is the promotion of a single container
+type Container struct {
+	Name string
+	// Source is the image the source runs, Image the image set on the target
+	Source string
+	Image  string
+	// Current is the image the target runs
+	Current string
+	// Pinned is true when Image references the digest the source runs
+	Pinned bool
+}
+
+// Changed reports whether the promotion changes the image of the container
+func (c Container) Changed() bool {
+	return c.Image != c.Current
+}
+
+// New creates a Promoter from the source and target options, each with its own Clientset.
+// The target updates are checked against the configuration.
+func New(source, target *types.Options, cfg *config.Config) *Promoter {
+	return &Promoter{
+		source: source,
+		target: target,
+		cfg:    cfg,
+	}
+}
+
+// Plan reads the images of the source and compares them with the target
+func (p *Promoter) Plan(ctx context.Context) (*Plan, error) {
+	source, err := getter.New(p.source).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	target, err := getter.New(p.target).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	var digests map[string]string
+	if p.source.PinDigest {
+		digests, err = getter.New(p.source).Digests(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("source: %w", err)
+		}
+	}
+
+	current := make(map[string]string, len(target.Containers))
+	for _, container := range target.Containers {
+		current[container.Name] = container.Image
+	}
+
+	plan := &Plan{}
+	for _, container := range source.Containers {
+		if p.source.ContainerName != "" && container.Name != p.source.ContainerName {
+			continue
+		}
+		if _, exists := current[container.Name]; !exists {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("container %s does not exist on the target, skipped", container.Name))
+			continue
+		}
+
+		promoted := Container{
+			Name:    container.Name,
+			Source:  container.Image,
+			Image:   container.Image,
+			Current: current[container.Name],
+		}
+		if p.source.PinDigest {
+			digest, err := p.digest(ctx, source, container.Name, container.Image, digests)
+			if err != nil {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("container %s: promoting tag, digest unknown: %v", container.Name, err))
+			} else {
+				promoted.Image = getter.WithDigest(container.Image, digest)
+				promoted.Pinned = true
+			}
+		}
+		plan.Containers = append(plan.Containers, promoted)
+	}
+
+	if len(plan.Containers) == 0 {
+		return nil, fmt.Errorf("no containers of %s %s to promote", p.source.ResourceType, p.source.ResourceName)
+	}
+	return plan, nil
+}
+
+// digest returns the digest the source runs for a container, asking the registry
+// when no running pod reports it
+func (p *Promoter) digest(ctx context.Context, source *getter.Result, container, image string, running map[string]string) (string, error) {
+	if digest, exists := running[container]; exists {
+		return digest, nil
+	}
+
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	client := registry.New(p.source)
+	client.UsePullSecrets(source.ServiceAccountName, source.ImagePullSecrets)
+	return client.Resolve(ctx, ref)
+}
+
+// Apply sets the planned images on the target in a single update, waiting for the rollout when requested
+func (p *Promoter) Apply(ctx context.Context, plan *Plan) (*setter.Result, error) {
+	options := *p.target
+	options.ContainerImages = make(map[string]string, len(plan.Containers))
+	for _, container := range plan.Containers {
+		options.ContainerImages[container.Name] = container.Image
+	}
+	// The containers are selected by the plan
+	options.ContainerName = ""
+
+	return setter.New(&options, p.cfg).Set(ctx)
+}
@@ -0,0 +1,144 @@
+package reference
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultDomain is the registry used for images without a registry host
+	DefaultDomain = "docker.io"
+	// DefaultTag is the tag used for images without a tag or digest
+	DefaultTag = "latest"
+
+	// dockerHubAPIDomain is the host serving the registry API for docker.io
+	dockerHubAPIDomain = "registry-1.docker.io"
+	// officialRepoPrefix is the namespace of single component Docker Hub images
+	officialRepoPrefix = "library/"
+)
+
+var (
+	tagPattern    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	pathPattern   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+)
+
+// Reference is a parsed container image reference such as registry.corp:5000/team/app:v1@sha256:...
+type Reference struct {
+	// Domain is the registry host, including the port if any
+	Domain string
+	// Path is the repository path within the registry
+	Path string
+	// Tag is empty when the reference has no tag
+	Tag string
+	// Digest is empty when the reference is not pinned
+	Digest string
+}
+
+// Parse parses an image reference, applying the same defaults as the container runtime
+func Parse(image string) (*Reference, error) {
+	if image == "" {
+		return nil, fmt.Errorf("empty image reference")
+	}
+
+	ref := &Reference{}
+	remainder := image
+
+	if at := strings.Index(remainder, "@"); at != -1 {
+		ref.Digest = remainder[at+1:]
+		remainder = remainder[:at]
+		if !digestPattern.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid digest %q in %q", ref.Digest, image)
+		}
+	}
+
+	// The tag follows the last colon, unless that colon is part of the registry host
+	if colon := strings.LastIndex(remainder, ":"); colon != -1 && !strings.Contains(remainder[colon+1:], "/") {
+		ref.Tag = remainder[colon+1:]
+		remainder = remainder[:colon]
+		if !tagPattern.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid tag %q in %q", ref.Tag, image)
+		}
+	}
+
+	ref.Domain, ref.Path = splitDomain(remainder)
+	if !pathPattern.MatchString(ref.Path) {
+		return nil, fmt.Errorf("invalid repository name %q in %q", ref.Path, image)
+	}
+
+	return ref, nil
+}
+
+// splitDomain splits the registry host from the repository path
+func splitDomain(name string) (string, string) {
+	slash := strings.Index(name, "/")
+	if slash == -1 {
+		return DefaultDomain, officialRepoPrefix + name
+	}
+
+	// The first component is a host if it looks like one
+	first := name[:slash]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" && strings.ToLower(first) == first {
+		return DefaultDomain, name
+	}
+
+	domain, path := first, name[slash+1:]
+	if domain == "index.docker.io" {
+		domain = DefaultDomain
+	}
+	if domain == DefaultDomain && !strings.Contains(path, "/") {
+		path = officialRepoPrefix + path
+	}
+	return domain, path
+}
+
+// Name returns the fully qualified repository name without tag or digest
+func (r *Reference) Name() string {
+	return r.Domain + "/" + r.Path
+}
+
+// String returns the fully qualified reference
+func (r *Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// TagOrDefault returns the tag, or "latest" when the reference has neither tag nor digest
+func (r *Reference) TagOrDefault() string {
+	if r.Tag == "" && r.Digest == "" {
+		return DefaultTag
+	}
+	return r.Tag
+}
+
+// Identifier returns the digest if pinned, otherwise the tag, as used in manifest URLs
+func (r *Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.TagOrDefault()
+}
+
+// APIDomain returns the host serving the registry API for this reference
+func (r *Reference) APIDomain() string {
+	if r.Domain == DefaultDomain {
+		return dockerHubAPIDomain
+	}
+	return r.Domain
+}
+
+// WithTag returns a copy of the reference pointing at another tag, without digest
+func (r *Reference) WithTag(tag string) *Reference {
+	return &Reference{
+		Domain: r.Domain,
+		Path:   r.Path,
+		Tag:    tag,
+	}
+}
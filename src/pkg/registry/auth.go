@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+)
+
+// challenge is a parsed WWW-Authenticate header
+type challenge struct {
+	Scheme string
+	Params map[string]string
+}
+
+// authorize answers an authentication challenge and returns the Authorization header to use
+func (c *Client) authorize(ctx context.Context, ref *reference.Reference, scope, header string) (string, error) {
+	ch, err := parseChallenge(header)
+	if err != nil {
+		return "", fmt.Errorf("unauthorized by registry %s: %w", ref.Domain, err)
+	}
+
+	credential, err := c.credential(ctx, ref.Domain)
+	if err != nil {
+		return "", err
+	}
+
+	var authorization string
+	switch ch.Scheme {
+	case "basic":
+		if credential == nil || credential.Username == "" {
+			return "", fmt.Errorf("registry %s requires basic authentication, but no credentials were found "+
+				"in the docker config, the imagePullSecrets or the ServiceAccount pull secrets", ref.Domain)
+		}
+		authorization = basicAuthorization(credential.Username, credential.Password)
+	case "bearer":
+		if credential != nil && credential.RegistryToken != "" {
+			authorization = "Bearer " + credential.RegistryToken
+			break
+		}
+		token, err := c.fetchToken(ctx, ref, ch, scope, credential)
+		if err != nil {
+			return "", err
+		}
+		authorization = "Bearer " + token
+	default:
+		return "", fmt.Errorf("registry %s requires unsupported %s authentication", ref.Domain, ch.Scheme)
+	}
+
+	c.storeToken(ref, scope, authorization)
+	return authorization, nil
+}
+
+// fetchToken obtains a bearer token from the token service named in the challenge.
+// Without credentials an anonymous token is requested.
+func (c *Client) fetchToken(ctx context.Context, ref *reference.Reference, ch *challenge, scope string, credential *Credential) (string, error) {
+	realm := ch.Params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry %s sent a bearer challenge without realm", ref.Domain)
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	if challengeScope := ch.Params["scope"]; challengeScope != "" {
+		scope = challengeScope
+	}
+
+	var req *http.Request
+	if credential != nil && credential.IdentityToken != "" {
+		// OAuth2 refresh token flow, used by docker login with identity tokens
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", credential.IdentityToken)
+		form.Set("service", ch.Params["service"])
+		form.Set("scope", scope)
+		form.Set("client_id", "kubectl-image")
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, tokenURL.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := tokenURL.Query()
+		if service := ch.Params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		query.Set("scope", scope)
+		tokenURL.RawQuery = query.Encode()
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if credential != nil && credential.Username != "" {
+			req.Header.Set("Authorization", basicAuthorization(credential.Username, credential.Password))
+		}
+	}
+	req.Header.Set("User-Agent", "kubectl-image")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request to %s failed: %w", tokenURL.Host, err)
+	}
+	defer drain(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("token request to %s failed: %w", tokenURL.Host, newError(resp))
+		if credential != nil && credential.Source != "" {
+			err = fmt.Errorf("%w (credentials from %s)", err, credential.Source)
+		}
+		return "", err
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response from %s: %w", tokenURL.Host, err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token response from %s contains no token", tokenURL.Host)
+}
+
+// basicAuthorization returns the Authorization header value for basic authentication
+func basicAuthorization(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) (*challenge, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, fmt.Errorf("missing WWW-Authenticate header")
+	}
+
+	scheme, rest, _ := strings.Cut(header, " ")
+	ch := &challenge{
+		Scheme: strings.ToLower(scheme),
+		Params: make(map[string]string),
+	}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(strings.TrimSpace(rest), ",") {
+		rest = strings.TrimSpace(rest)
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			// Quoted values may contain commas, e.g. in scopes
+			end := strings.Index(value[1:], `"`)
+			if end == -1 {
+				return nil, fmt.Errorf("malformed WWW-Authenticate header %q", header)
+			}
+			ch.Params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			ch.Params[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,231 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// tagsPageSize is the number of tags requested per page
+const tagsPageSize = 1000
+
+// linkNextPattern extracts the next page URL from a Link header
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// Client talks to container registries using the OCI distribution API
+type Client struct {
+	options    *types.Options
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// tokens caches Authorization header values by registry host and scope
+	tokens map[string]string
+	// credentials caches resolved credentials by registry host
+	credentials map[string]*Credential
+
+	// Pull secrets of the workload, read from the cluster as a fallback
+	serviceAccountName string
+	pullSecrets        []string
+}
+
+// Error is an error response returned by a registry
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// IsNotFound reports whether the error means the repository or manifest does not exist
+func IsNotFound(err error) bool {
+	var regErr *Error
+	return errors.As(err, &regErr) && regErr.StatusCode == http.StatusNotFound
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("registry returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("registry returned %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// New creates a new registry Client
+func New(options *types.Options) *Client {
+	return &Client{
+		options:     options,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		tokens:      make(map[string]string),
+		credentials: make(map[string]*Credential),
+	}
+}
+
+// ListTags returns all tags of the repository, following pagination
+func (c *Client) ListTags(ctx context.Context, ref *reference.Reference) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", c.baseURL(ref), ref.Path, tagsPageSize)
+
+	for next != "" {
+		resp, err := c.do(ctx, ref, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tag list of %s: %w", ref.Name(), err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next, err = c.nextPage(resp, next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// nextPage resolves the next page URL from the Link header, relative to the current URL
+func (c *Client) nextPage(resp *http.Response, current string) (string, error) {
+	match := linkNextPattern.FindStringSubmatch(resp.Header.Get("Link"))
+	if match == nil {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	next, err := base.Parse(match[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid pagination link %q: %w", match[1], err)
+	}
+	return next.String(), nil
+}
+
+// baseURL returns the scheme and host of the registry API
+func (c *Client) baseURL(ref *reference.Reference) string {
+	scheme := "https"
+	if c.options.PlainHTTP || isLocalhost(ref.APIDomain()) {
+		scheme = "http"
+	}
+	return scheme + "://" + ref.APIDomain()
+}
+
+// do sends a request to the registry, answering authentication challenges
+func (c *Client) do(ctx context.Context, ref *reference.Reference, method, rawURL string, accept []string) (*http.Response, error) {
+	scope := "repository:" + ref.Path + ":pull"
+
+	resp, err := c.send(ctx, method, rawURL, accept, c.cachedToken(ref, scope))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		drain(resp)
+
+		authorization, err := c.authorize(ctx, ref, scope, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = c.send(ctx, method, rawURL, accept, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode >= 300 {
+		defer drain(resp)
+		return nil, newError(resp)
+	}
+
+	return resp, nil
+}
+
+// send performs a single HTTP request
+func (c *Client) send(ctx context.Context, method, rawURL string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("User-Agent", "kubectl-image")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// cachedToken returns a previously obtained Authorization header value
+func (c *Client) cachedToken(ref *reference.Reference, scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[ref.APIDomain()+"|"+scope]
+}
+
+// storeToken caches an Authorization header value
+func (c *Client) storeToken(ref *reference.Reference, scope, authorization string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[ref.APIDomain()+"|"+scope] = authorization
+}
+
+// newError builds an Error from an error response
+func newError(resp *http.Response) error {
+	regErr := &Error{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body); err == nil && len(body.Errors) > 0 {
+		regErr.Code = body.Errors[0].Code
+		regErr.Message = body.Errors[0].Message
+	}
+
+	return regErr
+}
+
+// drain discards and closes the response body so the connection can be reused
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
+
+// isLocalhost reports whether the registry runs on the local machine, which is assumed to speak plain HTTP
+func isLocalhost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// newTestRegistry starts a registry stand-in and returns a reference to team/app:tag on it.
+// The docker config is pointed at an empty directory, so the user's credentials are never read.
+func newTestRegistry(t *testing.T, handler http.Handler, tag string) *reference.Reference {
+	t.Helper()
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ref, err := reference.Parse(strings.TrimPrefix(server.URL, "http://") + "/team/app:" + tag)
+	if err != nil {
+		t.Fatalf("parse reference: %v", err)
+	}
+	return ref
+}
+
+// writeJSON writes a JSON response with a content type
+func writeJSON(w http.ResponseWriter, contentType string, value any) {
+	w.Header().Set("Content-Type", contentType)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func TestListTagsFollowsPaginationWithBearerToken(t *testing.T) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if scope := r.URL.Query().Get("scope"); scope != "repository:team/app:pull" {
+			t.Errorf("token scope = %q", scope)
+		}
+		writeJSON(w, "application/json", map[string]string{"token": "secret"})
+	})
+	var realm string
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service="test"`, realm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/team/app/tags/list?n=2&last=1.1.0>; rel="next"`)
+			writeJSON(w, "application/json", map[string]any{"tags": []string{"1.0.0", "1.1.0"}})
+			return
+		}
+		writeJSON(w, "application/json", map[string]any{"tags": []string{"2.0.0"}})
+	})
+	ref := newTestRegistry(t, mux, "1.0.0")
+	realm = "http://" + ref.Domain + "/token"
+
+	tags, err := New(&types.Options{}).ListTags(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if want := []string{"1.0.0", "1.1.0", "2.0.0"}; !slices.Equal(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requested %d times, want the token to be reused", tokenRequests)
+	}
+}
+
+func TestListTagsUsesDockerConfigForBasicAuth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "ci" || password != "hunter2" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, "application/json", map[string]any{"tags": []string{"1.0.0"}})
+	})
+	ref := newTestRegistry(t, mux, "1.0.0")
+
+	auth := base64.StdEncoding.EncodeToString([]byte("ci:hunter2"))
+	config := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, ref.Domain, auth)
+	if err := os.WriteFile(filepath.Join(os.Getenv("DOCKER_CONFIG"), "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := New(&types.Options{}).ListTags(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if !slices.Equal(tags, []string{"1.0.0"}) {
+		t.Errorf("tags = %v", tags)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(headerDockerContentDigest, "sha256:abc")
+	})
+	ref := newTestRegistry(t, mux, "1.0.0")
+	client := New(&types.Options{})
+
+	digest, err := client.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if digest != "sha256:abc" {
+		t.Errorf("digest = %q", digest)
+	}
+
+	_, err = client.Resolve(context.Background(), ref.WithTag("missing"))
+	if !IsNotFound(err) {
+		t.Errorf("Resolve of a missing tag = %v, want a not found error", err)
+	}
+}
+
+func TestCreated(t *testing.T) {
+	annotated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	configured := time.Date(2024, 6, 2, 8, 30, 0, 0, time.UTC)
+
+	manifests := map[string]any{
+		// OCI annotation on the manifest itself
+		"annotated": map[string]any{
+			"mediaType":   MediaTypeOCIManifest,
+			"annotations": map[string]string{annotationCreated: annotated.Format(time.RFC3339)},
+		},
+		// Multi-platform index whose first manifest points to a config with a created field
+		"index": map[string]any{
+			"mediaType": MediaTypeOCIIndex,
+			"manifests": []map[string]any{{
+				"mediaType": MediaTypeOCIManifest,
+				"digest":    "sha256:child",
+				"platform":  map[string]string{"os": "linux", "architecture": "amd64"},
+			}},
+		},
+		"sha256:child": map[string]any{
+			"mediaType": MediaTypeOCIManifest,
+			"config":    map[string]string{"digest": "sha256:config"},
+		},
+		// Neither annotation nor config
+		"bare": map[string]any{"mediaType": MediaTypeDockerManifest},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/manifests/{reference}", func(w http.ResponseWriter, r *http.Request) {
+		manifest, exists := manifests[r.PathValue("reference")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, MediaTypeOCIManifest, manifest)
+	})
+	mux.HandleFunc("/v2/team/app/blobs/sha256:config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, "application/json", map[string]string{"created": configured.Format(time.RFC3339Nano)})
+	})
+	ref := newTestRegistry(t, mux, "annotated")
+	client := New(&types.Options{})
+
+	tests := []struct {
+		tag     string
+		want    time.Time
+		wantErr bool
+	}{
+		{tag: "annotated", want: annotated},
+		{tag: "index", want: configured},
+		{tag: "bare"},
+		{tag: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			created, err := client.Created(context.Background(), ref.WithTag(tt.tag))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Created error = %v, want error %v", err, tt.wantErr)
+			}
+			if !created.Equal(tt.want) {
+				t.Errorf("Created = %v, want %v", created, tt.want)
+			}
+		})
+	}
+}
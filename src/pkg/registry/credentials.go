@@ -0,0 +1,390 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/homedir"
+)
+
+// identityTokenUsername is returned by credential helpers for OAuth2 refresh tokens
+const identityTokenUsername = "<token>"
+
+// dockerHubConfigKey is the key docker login uses for Docker Hub
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// Credential holds the secrets used to authenticate against a registry
+type Credential struct {
+	Username string
+	Password string
+	// IdentityToken is an OAuth2 refresh token exchanged for a bearer token
+	IdentityToken string
+	// RegistryToken is a bearer token sent to the registry as is
+	RegistryToken string
+	// Source describes where the credential was found, for error messages
+	Source string
+}
+
+// dockerConfig is the subset of ~/.docker/config.json and kubernetes.io/dockerconfigjson secrets we use
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore,omitempty"`
+	CredHelpers map[string]string     `json:"credHelpers,omitempty"`
+}
+
+// dockerAuth is a single entry of the auths section
+type dockerAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+// UsePullSecrets makes the client fall back to the pull secrets of a workload and
+// its ServiceAccount when the local docker config has no credentials for a registry
+func (c *Client) UsePullSecrets(serviceAccountName string, secretNames []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	c.serviceAccountName = serviceAccountName
+	c.pullSecrets = secretNames
+	c.credentials = make(map[string]*Credential)
+}
+
+// UsePodSpecPullSecrets is a shortcut for UsePullSecrets with the pull secrets of a pod spec
+func (c *Client) UsePodSpecPullSecrets(podSpec *corev1.PodSpec) {
+	names := make([]string, 0, len(podSpec.ImagePullSecrets))
+	for _, secret := range podSpec.ImagePullSecrets {
+		names = append(names, secret.Name)
+	}
+	c.UsePullSecrets(podSpec.ServiceAccountName, names)
+}
+
+// credential resolves the credentials for a registry host, in the order
+// docker config (auths and credential helpers), workload imagePullSecrets, ServiceAccount pull secrets.
+// It returns nil when no credentials are configured.
+func (c *Client) credential(ctx context.Context, domain string) (*Credential, error) {
+	c.mu.Lock()
+	cached, exists := c.credentials[domain]
+	c.mu.Unlock()
+	if exists {
+		return cached, nil
+	}
+
+	credential, err := c.lookupCredential(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.credentials[domain] = credential
+	c.mu.Unlock()
+	return credential, nil
+}
+
+// lookupCredential performs the credential resolution without caching
+func (c *Client) lookupCredential(ctx context.Context, domain string) (*Credential, error) {
+	config, err := loadDockerConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		credential, err := config.lookup(domain, true)
+		var missing *missingHelperError
+		switch {
+		case errors.As(err, &missing):
+			// Configs copied to CI runners often name a helper that is not installed there
+			if c.options.ErrOut != nil {
+				fmt.Fprintf(c.options.ErrOut, "Warning: %v, accessing %s without docker credentials\n", err, domain)
+			}
+		case err != nil:
+			return nil, err
+		case credential != nil:
+			return credential, nil
+		}
+	}
+
+	if c.options.Clientset == nil || c.options.Namespace == "" {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	secretNames := c.pullSecrets
+	serviceAccountName := c.serviceAccountName
+	c.mu.Unlock()
+
+	credential, err := c.lookupPullSecrets(ctx, domain, secretNames, "imagePullSecret")
+	if err != nil || credential != nil {
+		return credential, err
+	}
+
+	if serviceAccountName == "" {
+		return nil, nil
+	}
+	serviceAccount, err := c.options.Clientset.CoreV1().ServiceAccounts(c.options.Namespace).Get(ctx, serviceAccountName, metav1.GetOptions{})
+	if err != nil {
+		// Missing permissions to read ServiceAccounts must not break anonymous pulls
+		return nil, nil
+	}
+	names := make([]string, 0, len(serviceAccount.ImagePullSecrets))
+	for _, secret := range serviceAccount.ImagePullSecrets {
+		names = append(names, secret.Name)
+	}
+	return c.lookupPullSecrets(ctx, domain, names, "serviceaccount/"+serviceAccountName+" imagePullSecret")
+}
+
+// HasDockerCredentials reports whether the docker config has credentials for a registry host,
+// an auths entry or a credential helper, without running the helper
+func HasDockerCredentials(domain string) bool {
+	config, err := loadDockerConfig()
+	if err != nil || config == nil {
+		return false
+	}
+	if _, exists := config.CredHelpers[domain]; exists {
+		return true
+	}
+	for key := range config.Auths {
+		if matchConfigKey(key, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupPullSecrets returns the first credential for domain found in the given pull secrets
+func (c *Client) lookupPullSecrets(ctx context.Context, domain string, names []string, kind string) (*Credential, error) {
+	for _, name := range names {
+		secret, err := c.options.Clientset.CoreV1().Secrets(c.options.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			// The kubelet also skips pull secrets it cannot read
+			continue
+		}
+
+		config, err := parsePullSecret(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %w", kind, name, err)
+		}
+		if config == nil {
+			continue
+		}
+
+		// Credential helpers only exist on the local machine
+		credential, err := config.lookup(domain, false)
+		if err != nil {
+			return nil, err
+		}
+		if credential != nil {
+			credential.Source = fmt.Sprintf("%s %s", kind, name)
+			return credential, nil
+		}
+	}
+	return nil, nil
+}
+
+// parsePullSecret decodes a kubernetes.io/dockerconfigjson or kubernetes.io/dockercfg secret
+func parsePullSecret(secret *corev1.Secret) (*dockerConfig, error) {
+	switch secret.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		config := &dockerConfig{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	case corev1.SecretTypeDockercfg:
+		config := &dockerConfig{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &config.Auths); err != nil {
+			return nil, err
+		}
+		return config, nil
+	default:
+		return nil, nil
+	}
+}
+
+// loadDockerConfig reads $DOCKER_CONFIG/config.json or ~/.docker/config.json, nil if it does not exist
+func loadDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home := homedir.HomeDir()
+		if home == "" {
+			return nil, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read docker config: %w", err)
+	}
+
+	config := &dockerConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config %s: %w", filepath.Join(dir, "config.json"), err)
+	}
+	return config, nil
+}
+
+// lookup finds the credentials for a registry host in the config
+func (d *dockerConfig) lookup(domain string, helpers bool) (*Credential, error) {
+	if helpers {
+		if helper, exists := d.CredHelpers[domain]; exists {
+			return runCredentialHelper(helper, domain)
+		}
+	}
+
+	for key, auth := range d.Auths {
+		if !matchConfigKey(key, domain) {
+			continue
+		}
+		credential, err := auth.credential()
+		if err != nil {
+			return nil, fmt.Errorf("invalid docker config entry %s: %w", key, err)
+		}
+		if credential != nil {
+			credential.Source = "docker config"
+			return credential, nil
+		}
+	}
+
+	// The default store holds the credentials of docker login when auths only lists the host
+	if helpers && d.CredsStore != "" {
+		return runCredentialHelper(d.CredsStore, configKeyFor(domain))
+	}
+
+	return nil, nil
+}
+
+// credential decodes a docker config auths entry
+func (a dockerAuth) credential() (*Credential, error) {
+	credential := &Credential{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+		RegistryToken: a.RegistryToken,
+	}
+
+	if a.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("auth is not valid base64: %w", err)
+		}
+		username, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return nil, fmt.Errorf("auth is not in user:password format")
+		}
+		credential.Username, credential.Password = username, password
+	}
+
+	if credential.Username == "" && credential.Password == "" && credential.IdentityToken == "" && credential.RegistryToken == "" {
+		return nil, nil
+	}
+	return credential, nil
+}
+
+// missingHelperError is returned when the credential helper of the docker config is not installed
+type missingHelperError struct {
+	Helper string
+}
+
+func (e *missingHelperError) Error() string {
+	return fmt.Sprintf("credential helper docker-credential-%s is not installed", e.Helper)
+}
+
+// runCredentialHelper asks a docker-credential-<helper> binary for the credentials of a server
+func runCredentialHelper(helper, server string) (*Credential, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, &missingHelperError{Helper: helper}
+		}
+		// Helpers report unknown servers on stdout with a non-zero exit code
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("credential helper docker-credential-%s failed: %v %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var response struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("invalid response from docker-credential-%s: %w", helper, err)
+	}
+
+	credential := &Credential{Source: "docker-credential-" + helper}
+	if response.Username == identityTokenUsername {
+		credential.IdentityToken = response.Secret
+	} else {
+		credential.Username = response.Username
+		credential.Password = response.Secret
+	}
+	return credential, nil
+}
+
+// matchConfigKey reports whether a docker config key such as "https://registry.corp/v1/"
+// or "*.corp" applies to a registry host
+func matchConfigKey(key, domain string) bool {
+	host := key
+	if i := strings.Index(host, "://"); i != -1 {
+		host = host[i+3:]
+	}
+	if i := strings.Index(host, "/"); i != -1 {
+		host = host[:i]
+	}
+
+	if host == domain {
+		return true
+	}
+	// Docker Hub is stored under its legacy index host
+	if isDockerHub(host) && isDockerHub(domain) {
+		return true
+	}
+	// Wildcards are supported like the kubelet does, e.g. "*.registry.corp"
+	if strings.Contains(host, "*") {
+		matched, _ := path.Match(host, domain)
+		return matched
+	}
+	return false
+}
+
+// configKeyFor returns the key docker login uses for a registry host
+func configKeyFor(domain string) string {
+	if isDockerHub(domain) {
+		return dockerHubConfigKey
+	}
+	return domain
+}
+
+// isDockerHub reports whether the host is one of the Docker Hub registry hosts
+func isDockerHub(host string) bool {
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return true
+	default:
+		return false
+	}
+}
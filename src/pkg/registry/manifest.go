@@ -0,0 +1,186 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+)
+
+// Manifest media types accepted from registries
+const (
+	MediaTypeOCIIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList       = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest   = "application/vnd.docker.distribution.manifest.v2+json"
+	annotationCreated         = "org.opencontainers.image.created"
+	headerDockerContentDigest = "Docker-Content-Digest"
+)
+
+// manifestMediaTypes is sent as Accept header for manifest requests
+var manifestMediaTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifest,
+}
+
+// Platform identifies the platform an image manifest is built for
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform as os/arch[/variant]
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Descriptor references content in a registry
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an image manifest or an image index (manifest list)
+type Manifest struct {
+	MediaType   string            `json:"mediaType"`
+	Config      Descriptor        `json:"config"`
+	Manifests   []Descriptor      `json:"manifests"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Digest is the content digest reported by the registry
+	Digest string `json:"-"`
+}
+
+// IsIndex reports whether the manifest is a multi-platform index
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeOCIIndex || m.MediaType == MediaTypeDockerList || len(m.Manifests) > 0
+}
+
+// GetManifest fetches the manifest the reference points to
+func (c *Client) GetManifest(ctx context.Context, ref *reference.Reference) (*Manifest, error) {
+	rawURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref), ref.Path, ref.Identifier())
+	resp, err := c.do(ctx, ref, http.MethodGet, rawURL, manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer drain(resp)
+
+	manifest := &Manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest of %s: %w", ref, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = resp.Header.Get("Content-Type")
+	}
+	manifest.Digest = resp.Header.Get(headerDockerContentDigest)
+
+	return manifest, nil
+}
+
+// Resolve checks that the reference exists with a HEAD request and returns its digest
+func (c *Client) Resolve(ctx context.Context, ref *reference.Reference) (string, error) {
+	rawURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref), ref.Path, ref.Identifier())
+	resp, err := c.do(ctx, ref, http.MethodHead, rawURL, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	drain(resp)
+
+	digest := resp.Header.Get(headerDockerContentDigest)
+	if digest == "" {
+		digest = ref.Digest
+	}
+	return digest, nil
+}
+
+// Platforms returns the platforms of a multi-platform image, nil for single platform images
+func (m *Manifest) Platforms() []Platform {
+	var platforms []Platform
+	for _, descriptor := range m.Manifests {
+		// Skip attestation manifests, they are not runnable images
+		if descriptor.Platform == nil || descriptor.Platform.OS == "unknown" {
+			continue
+		}
+		platforms = append(platforms, *descriptor.Platform)
+	}
+	return platforms
+}
+
+// Created returns when the image was built, using the OCI annotation or the image config.
+// A zero time is returned when the registry does not provide it.
+func (c *Client) Created(ctx context.Context, ref *reference.Reference) (time.Time, error) {
+	manifest, err := c.GetManifest(ctx, ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if created, ok := parseCreated(manifest.Annotations[annotationCreated]); ok {
+		return created, nil
+	}
+
+	if manifest.IsIndex() {
+		if len(manifest.Manifests) == 0 {
+			return time.Time{}, nil
+		}
+
+		// Any platform will do, they are built from the same source
+		child := manifest.Manifests[0]
+		if created, ok := parseCreated(child.Annotations[annotationCreated]); ok {
+			return created, nil
+		}
+
+		pinned := *ref
+		pinned.Digest = child.Digest
+		manifest, err = c.GetManifest(ctx, &pinned)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if created, ok := parseCreated(manifest.Annotations[annotationCreated]); ok {
+			return created, nil
+		}
+	}
+
+	if manifest.Config.Digest == "" {
+		return time.Time{}, nil
+	}
+
+	rawURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(ref), ref.Path, manifest.Config.Digest)
+	resp, err := c.do(ctx, ref, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer drain(resp)
+
+	var config struct {
+		Created string `json:"created"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode image config of %s: %w", ref, err)
+	}
+	created, _ := parseCreated(config.Created)
+	return created, nil
+}
+
+// parseCreated parses an RFC 3339 timestamp
+func parseCreated(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return created, true
+}
@@ -0,0 +1,203 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxWarningDigest is the number of warnings kept for the failure digest
+const maxWarningDigest = 10
+
+// revisionAnnotation is set by the deployment controller on deployments and their ReplicaSets
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// eventTracker collects Kubernetes Events related to a deployment rollout
+type eventTracker struct {
+	clientset kubernetes.Interface
+	namespace string
+	since     time.Time
+	progress  *Printer
+
+	// seen maps an event UID to the last count that was printed
+	seen     map[k8stypes.UID]int32
+	warnings []string
+
+	// disabled stops polling after the first failure, e.g. when events cannot be listed
+	disabled bool
+}
+
+// newEventTracker creates a tracker that reports events that happened after since
+func newEventTracker(clientset kubernetes.Interface, namespace string, since time.Time, progress *Printer) *eventTracker {
+	return &eventTracker{
+		clientset: clientset,
+		namespace: namespace,
+		// Event timestamps only have second precision
+		since:    since.Truncate(time.Second),
+		progress: progress,
+		seen:     make(map[k8stypes.UID]int32),
+	}
+}
+
+// poll prints new events for the deployment, its ReplicaSets and the pods of the new ReplicaSet
+func (t *eventTracker) poll(ctx context.Context, deployment *appsv1.Deployment, pods []corev1.Pod) error {
+	if t.disabled {
+		return nil
+	}
+
+	objects, err := t.involvedObjects(ctx, deployment, pods)
+	if err != nil {
+		t.disabled = true
+		return err
+	}
+
+	// Only the events of the rollout's objects are listed, busy namespaces have many more
+	var events []corev1.Event
+	for _, uid := range objects {
+		eventList, err := t.clientset.CoreV1().Events(t.namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(uid)).String(),
+		})
+		if err != nil {
+			t.disabled = true
+			return fmt.Errorf("failed to list events: %v", err)
+		}
+		for _, event := range eventList.Items {
+			if event.InvolvedObject.UID == uid && !eventTime(event).Before(t.since) {
+				events = append(events, event)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+
+	for _, event := range events {
+
+		// De-duplicate repeated events, they are aggregated by the API server into a single object
+		count := event.Count
+		if event.Series != nil {
+			count = event.Series.Count
+		}
+		if last, exists := t.seen[event.UID]; exists && last >= count {
+			continue
+		}
+		t.seen[event.UID] = count
+
+		line := formatEvent(event)
+		icon := t.progress.Icon("📣", "[EVENT]")
+		if event.Type == corev1.EventTypeWarning {
+			icon = t.progress.Icon("⚠️", "[WARN]")
+			t.recordWarning(line)
+		}
+
+		t.progress.Emit(Event{
+			Event:      EventKubernetesEvent,
+			Namespace:  t.namespace,
+			Deployment: deployment.Name,
+			K8sEvent: &KubernetesEvent{
+				Type:    event.Type,
+				Object:  strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name,
+				Reason:  event.Reason,
+				Message: strings.TrimSpace(event.Message),
+				Count:   count,
+			},
+		}, fmt.Sprintf(" %s  %s", icon, line))
+	}
+
+	return nil
+}
+
+// involvedObjects returns the UIDs of all objects whose events are relevant to the rollout:
+// the deployment, its ReplicaSets that have or should have pods, and the new pods
+func (t *eventTracker) involvedObjects(ctx context.Context, deployment *appsv1.Deployment, pods []corev1.Pod) ([]k8stypes.UID, error) {
+	objects := []k8stypes.UID{deployment.UID}
+
+	rsList, err := t.clientset.AppsV1().ReplicaSets(t.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets for deployment %s: %v", deployment.Name, err)
+	}
+
+	var newReplicaSet k8stypes.UID
+	for _, rs := range rsList.Items {
+		if !metav1.IsControlledBy(&rs, deployment) {
+			continue
+		}
+		isNew := rs.Annotations[revisionAnnotation] == deployment.Annotations[revisionAnnotation]
+		if isNew {
+			newReplicaSet = rs.UID
+		}
+		// Old ReplicaSets scaled to zero before the rollout have nothing to report
+		if isNew || rs.Status.Replicas > 0 || (rs.Spec.Replicas != nil && *rs.Spec.Replicas > 0) {
+			objects = append(objects, rs.UID)
+		}
+	}
+
+	for _, pod := range pods {
+		// Only the new pods are interesting, unless the new ReplicaSet is not known yet
+		owner := metav1.GetControllerOf(&pod)
+		if newReplicaSet == "" || (owner != nil && owner.UID == newReplicaSet) {
+			objects = append(objects, pod.UID)
+		}
+	}
+
+	return objects, nil
+}
+
+// recordWarning keeps the most recent warnings for the failure digest
+func (t *eventTracker) recordWarning(line string) {
+	t.warnings = append(t.warnings, line)
+	if len(t.warnings) > maxWarningDigest {
+		t.warnings = t.warnings[len(t.warnings)-maxWarningDigest:]
+	}
+}
+
+// digest returns the last warnings seen during the rollout as printable lines
+func (t *eventTracker) digest() []string {
+	if len(t.warnings) == 0 {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("Last %d warning events:", len(t.warnings))}
+	for _, line := range t.warnings {
+		lines = append(lines, "  - "+line)
+	}
+	return lines
+}
+
+// eventTime returns the most recent time an event was observed
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case event.Series != nil:
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// formatEvent renders an event as a single line
+func formatEvent(event corev1.Event) string {
+	line := fmt.Sprintf("%s/%s %s: %s",
+		strings.ToLower(event.InvolvedObject.Kind),
+		event.InvolvedObject.Name,
+		event.Reason,
+		strings.TrimSpace(event.Message))
+	if event.Count > 1 {
+		line += fmt.Sprintf(" (x%d)", event.Count)
+	}
+	return line
+}
@@ -0,0 +1,111 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEventTrackerPoll(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	replicas := int32(1)
+	zero := int32(0)
+	controller := true
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "prod", UID: "deploy", Annotations: map[string]string{revisionAnnotation: "2"}},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app"}}},
+	}
+	replicaSet := func(uid, revision string, replicas *int32) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "app-" + uid,
+				Namespace:       "prod",
+				UID:             k8stypes.UID(uid),
+				Labels:          map[string]string{"app": "app"},
+				Annotations:     map[string]string{revisionAnnotation: revision},
+				OwnerReferences: []metav1.OwnerReference{{UID: "deploy", Controller: &controller}},
+			},
+			Spec: appsv1.ReplicaSetSpec{Replicas: replicas},
+		}
+	}
+	event := func(name, kind, uid, reason string, at time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "prod", UID: k8stypes.UID(name)},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: "app-" + uid, UID: k8stypes.UID(uid)},
+			Reason:         reason,
+			Message:        reason + " message",
+			Type:           corev1.EventTypeNormal,
+			Count:          1,
+			LastTimestamp:  metav1.NewTime(at),
+		}
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "app-new-1",
+		Namespace:       "prod",
+		UID:             "pod",
+		OwnerReferences: []metav1.OwnerReference{{UID: "new", Controller: &controller}},
+	}}
+
+	clientset := fake.NewClientset(
+		deployment,
+		replicaSet("new", "2", &replicas),
+		replicaSet("old", "1", &replicas),
+		replicaSet("older", "0", &zero),
+		event("scaled", "Deployment", "deploy", "ScalingReplicaSet", start.Add(10*time.Second)),
+		event("created", "ReplicaSet", "new", "SuccessfulCreate", start.Add(20*time.Second)),
+		event("pulling", "Pod", "pod", "Pulling", start.Add(30*time.Second)),
+		event("deleted", "ReplicaSet", "old", "SuccessfulDelete", start.Add(40*time.Second)),
+		event("stale", "ReplicaSet", "older", "SuccessfulDelete", start.Add(50*time.Second)),
+		event("before", "Pod", "pod", "Scheduled", start.Add(-time.Hour)),
+		event("other", "Pod", "other", "Pulling", start.Add(30*time.Second)),
+	)
+
+	var out bytes.Buffer
+	tracker := newEventTracker(clientset, "prod", start, NewPrinter(&types.Options{Out: &out, NoEmoji: true}))
+	if err := tracker.poll(context.Background(), deployment, []corev1.Pod{pod}); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		got = append(got, fields[2])
+	}
+	want := []string{"ScalingReplicaSet:", "SuccessfulCreate:", "Pulling:", "SuccessfulDelete:"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v in order\n%s", got, want, out.String())
+	}
+
+	// Every events list is limited to one involved object
+	for _, action := range clientset.Actions() {
+		list, ok := action.(k8stesting.ListAction)
+		if !ok || action.GetResource().Resource != "events" {
+			continue
+		}
+		selector := list.GetListRestrictions().Fields.String()
+		if !strings.HasPrefix(selector, "involvedObject.uid=") {
+			t.Errorf("events listed with field selector %q, want one on involvedObject.uid", selector)
+		}
+	}
+
+	// Events already printed are not repeated
+	out.Reset()
+	if err := tracker.poll(context.Background(), deployment, []corev1.Pod{pod}); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("second poll printed %q, want nothing", out.String())
+	}
+}
@@ -0,0 +1,141 @@
+package rollout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// Progress event names emitted with --progress=json
+const (
+	EventTagResolved        = "tag_resolved"
+	EventImageVerified      = "image_verified"
+	EventImageUpdated       = "image_updated"
+	EventStarted            = "started"
+	EventProgress           = "progress"
+	EventKubernetesEvent    = "kubernetes_event"
+	EventPodsReady          = "pods_ready"
+	EventOldPodsTerminating = "old_pods_terminating"
+	EventCompleted          = "completed"
+	EventFailed             = "failed"
+	EventInterrupted        = "interrupted"
+)
+
+// Event is a single machine-readable progress record
+type Event struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Namespace  string    `json:"namespace"`
+	Deployment string    `json:"deployment"`
+	Message    string    `json:"message,omitempty"`
+
+	// Image updates
+	Tag       string `json:"tag,omitempty"`
+	Container string `json:"container,omitempty"`
+	OldImage  string `json:"oldImage,omitempty"`
+	NewImage  string `json:"newImage,omitempty"`
+
+	// Registry verification
+	Digest    string   `json:"digest,omitempty"`
+	Platforms []string `json:"platforms,omitempty"`
+
+	// Rollout settings
+	WaitFor        string  `json:"waitFor,omitempty"`
+	TimeoutSeconds float64 `json:"timeoutSeconds,omitempty"`
+
+	// Pod counts
+	Desired     *int32 `json:"desired,omitempty"`
+	Ready       *int   `json:"ready,omitempty"`
+	Pending     *int   `json:"pending,omitempty"`
+	Terminating *int   `json:"terminating,omitempty"`
+
+	// Durations
+	ElapsedSeconds float64 `json:"elapsedSeconds,omitempty"`
+	CleanupSeconds float64 `json:"cleanupSeconds,omitempty"`
+
+	Pods     []PodDetail      `json:"pods,omitempty"`
+	K8sEvent *KubernetesEvent `json:"kubernetesEvent,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// PodDetail describes a pod that is not ready yet
+type PodDetail struct {
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	Containers []ContainerDetail `json:"containers,omitempty"`
+}
+
+// ContainerDetail describes why a container is not ready
+type ContainerDetail struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// KubernetesEvent is a Kubernetes Event related to the rollout
+type KubernetesEvent struct {
+	Type    string `json:"type"`
+	Object  string `json:"object"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"`
+}
+
+// Printer renders rollout progress either for humans or as newline-delimited JSON
+type Printer struct {
+	format  types.ProgressFormat
+	emoji   bool
+	out     io.Writer
+	encoder *json.Encoder
+}
+
+// NewPrinter creates a Printer from the command line options
+func NewPrinter(options *types.Options) *Printer {
+	format, exists := types.ValidProgressFormats[strings.ToLower(options.Progress)]
+	if !exists {
+		format = types.ProgressHuman
+	}
+
+	out := options.Out
+	if out == nil {
+		out = io.Discard
+	}
+
+	return &Printer{
+		format:  format,
+		emoji:   !options.NoEmoji,
+		out:     out,
+		encoder: json.NewEncoder(out),
+	}
+}
+
+// Icon returns the emoji, or its plain text replacement when emoji are disabled
+func (p *Printer) Icon(emoji, plain string) string {
+	if p.emoji {
+		return emoji
+	}
+	return plain
+}
+
+// Emit prints the event as JSON, or the human readable lines otherwise
+func (p *Printer) Emit(event Event, lines ...string) {
+	if p.format == types.ProgressJSON {
+		event.Time = time.Now().UTC()
+		_ = p.encoder.Encode(event)
+		return
+	}
+
+	for _, line := range lines {
+		fmt.Fprintln(p.out, line)
+	}
+}
+
+// seconds converts a duration to fractional seconds rounded to milliseconds
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
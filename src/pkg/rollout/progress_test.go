@@ -0,0 +1,95 @@
+package rollout
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// TestEventFieldNames pins the keys of --progress=json, renaming one breaks its consumers
+func TestEventFieldNames(t *testing.T) {
+	desired := int32(3)
+	count := 2
+	data, err := json.Marshal(Event{
+		Event:          EventKubernetesEvent,
+		Namespace:      "prod",
+		Deployment:     "app",
+		Message:        "message",
+		Tag:            "1.1",
+		Container:      "app",
+		OldImage:       "app:1.0",
+		NewImage:       "app:1.1",
+		Digest:         "sha256:abc",
+		Platforms:      []string{"linux/amd64"},
+		WaitFor:        "ready",
+		TimeoutSeconds: 60,
+		Desired:        &desired,
+		Ready:          &count,
+		Pending:        &count,
+		Terminating:    &count,
+		ElapsedSeconds: 1,
+		CleanupSeconds: 1,
+		Pods:           []PodDetail{{Name: "app-1", Status: "Pending"}},
+		K8sEvent:       &KubernetesEvent{Type: "Warning", Object: "Pod/app-1", Reason: "BackOff", Message: "back-off", Count: 2},
+		Warnings:       []string{"warning"},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var got []string
+	for name := range fields {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"cleanupSeconds", "container", "deployment", "desired", "digest", "elapsedSeconds", "event",
+		"kubernetesEvent", "message", "namespace", "newImage", "oldImage", "pending", "platforms", "pods",
+		"ready", "tag", "terminating", "time", "timeoutSeconds", "waitFor", "warnings",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+
+	var event struct {
+		KubernetesEvent map[string]any `json:"kubernetesEvent"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, name := range []string{"type", "object", "reason", "message", "count"} {
+		if _, ok := event.KubernetesEvent[name]; !ok {
+			t.Errorf("kubernetesEvent has no %q field: %s", name, data)
+		}
+	}
+}
+
+func TestEmitJSON(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPrinter(&types.Options{Progress: "json", Out: &out})
+	p.Emit(Event{Event: EventStarted, Namespace: "prod", Deployment: "app"}, "human line")
+	p.Emit(Event{Event: EventCompleted, Namespace: "prod", Deployment: "app"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per event: %q", len(lines), out.String())
+	}
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("line %q has no time", line)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Watcher tracks the rollout of a deployment
+type Watcher struct {
+	options  *types.Options
+	progress *Printer
+}
+
+// Result describes a completed rollout
+type Result struct {
+	WaitFor     types.WaitCondition
+	Desired     int32
+	Ready       int
+	Terminating int
+	Duration    time.Duration
+	// CleanupDuration is the time spent waiting for old pods after the new pods became ready
+	CleanupDuration time.Duration
+}
+
+// New creates a new Watcher
+func New(options *types.Options) *Watcher {
+	return &Watcher{
+		options:  options,
+		progress: NewPrinter(options),
+	}
+}
+
+// wrapGetError turns API errors into typed errors where possible
+func (w *Watcher) wrapGetError(err error) error {
+	if apierrors.IsNotFound(err) {
+		return &types.NotFoundError{
+			ResourceType: string(types.ResourceTypeDeployment),
+			Name:         w.options.ResourceName,
+			Namespace:    w.options.Namespace,
+			Err:          err,
+		}
+	}
+	return fmt.Errorf("failed to get deployment %s: %w", w.options.ResourceName, err)
+}
+
+// resumeCommand returns the status command that attaches to the rollout again
+func (w *Watcher) resumeCommand() string {
+	command := fmt.Sprintf("kubectl image status deployment/%s -n %s --wait", w.options.ResourceName, w.options.Namespace)
+	if w.options.Context != "" {
+		command += " --context " + w.options.Context
+	}
+	return command
+}
+
+// defaultRolloutTimeout is used when the deployment has no progress deadline
+const defaultRolloutTimeout = 10 * time.Minute
+
+// Wait waits for the deployment rollout to complete
+func (w *Watcher) Wait(ctx context.Context) (*Result, error) {
+	deploymentsClient := w.options.Clientset.AppsV1().Deployments(w.options.Namespace)
+
+	waitFor, exists := types.ValidWaitConditions[strings.ToLower(w.options.WaitFor)]
+	if !exists {
+		waitFor = types.WaitForCleanup
+	}
+
+	pollInterval := w.options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+
+	// Default the timeout from the deployment's own progress deadline
+	timeout := w.options.Timeout
+	if timeout <= 0 {
+		deployment, err := deploymentsClient.Get(ctx, w.options.ResourceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, w.wrapGetError(err)
+		}
+		timeout = defaultRolloutTimeout
+		if deployment.Spec.ProgressDeadlineSeconds != nil {
+			timeout = time.Duration(*deployment.Spec.ProgressDeadlineSeconds) * time.Second
+		}
+	}
+
+	p := w.progress
+	p.Emit(Event{
+		Event:          EventStarted,
+		Namespace:      w.options.Namespace,
+		Deployment:     w.options.ResourceName,
+		WaitFor:        string(waitFor),
+		TimeoutSeconds: timeout.Seconds(),
+	}, fmt.Sprintf("Waiting for deployment %s rollout to complete (wait for %s, timeout %v)...", w.options.ResourceName, waitFor, timeout))
+
+	// Create a ticker for status updates
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// Create a timeout context
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	var deploymentReadyTime time.Time
+
+	// Last observed state, reported when the wait is interrupted
+	var lastDesired, lastUpdated int32
+	var lastReady, lastPending, lastTerminating int
+
+	// Events carry the real reason behind pending pods, e.g. FailedScheduling or FailedMount
+	events := newEventTracker(w.options.Clientset, w.options.Namespace, startTime, p)
+
+	// fail reports the failure together with the last warning events,
+	// the error itself is printed by the caller
+	fail := func(err error) (*Result, error) {
+		p.Emit(Event{
+			Event:          EventFailed,
+			Namespace:      w.options.Namespace,
+			Deployment:     w.options.ResourceName,
+			Message:        err.Error(),
+			ElapsedSeconds: seconds(time.Since(startTime)),
+			Warnings:       events.warnings,
+		}, events.digest()...)
+		return nil, &types.RolloutFailedError{
+			ResourceName: w.options.ResourceName,
+			Reason:       err.Error(),
+			Warnings:     events.warnings,
+			Err:          err,
+		}
+	}
+
+	// interrupted reports the last known rollout state when the caller cancels the wait
+	interrupted := func() (*Result, error) {
+		elapsed := time.Since(startTime).Round(time.Millisecond)
+		p.Emit(Event{
+			Event:          EventInterrupted,
+			Namespace:      w.options.Namespace,
+			Deployment:     w.options.ResourceName,
+			Message:        "wait interrupted, the rollout continues in the cluster",
+			Desired:        &lastDesired,
+			Ready:          &lastReady,
+			Pending:        &lastPending,
+			Terminating:    &lastTerminating,
+			ElapsedSeconds: seconds(elapsed),
+		},
+			"",
+			fmt.Sprintf(" %s  Wait interrupted after %v, the rollout continues in the cluster", p.Icon("🛑", "[STOP]"), elapsed),
+			fmt.Sprintf("     Last known state: %d/%d pods ready, %d updated, %d pending, %d terminating",
+				lastReady, lastDesired, lastUpdated, lastPending, lastTerminating),
+			"     Resume watching with: "+w.resumeCommand(),
+		)
+		return nil, fmt.Errorf("waiting for deployment %s rollout interrupted: %w", w.options.ResourceName, ctx.Err())
+	}
+
+	// complete reports the successful end of the wait
+	complete := func(message string, ready, terminating int, desired int32) (*Result, error) {
+		event := Event{
+			Event:          EventCompleted,
+			Namespace:      w.options.Namespace,
+			Deployment:     w.options.ResourceName,
+			Message:        message,
+			Desired:        &desired,
+			Ready:          &ready,
+			Terminating:    &terminating,
+			ElapsedSeconds: seconds(time.Since(startTime)),
+		}
+		if !deploymentReadyTime.IsZero() {
+			event.CleanupSeconds = seconds(time.Since(deploymentReadyTime))
+		}
+		p.Emit(event, fmt.Sprintf(" %s  %s", p.Icon("✅", "[OK]"), message))
+
+		result := &Result{
+			WaitFor:     waitFor,
+			Desired:     desired,
+			Ready:       ready,
+			Terminating: terminating,
+			Duration:    time.Since(startTime),
+		}
+		if !deploymentReadyTime.IsZero() {
+			result.CleanupDuration = time.Since(deploymentReadyTime)
+		}
+		return result, nil
+	}
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			return fail(fmt.Errorf("timeout waiting for deployment %s rollout to complete", w.options.ResourceName))
+		case <-ticker.C:
+			// Get the deployment
+			deployment, err := deploymentsClient.Get(ctx, w.options.ResourceName, metav1.GetOptions{})
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			if err != nil {
+				return fail(fmt.Errorf("failed to get deployment %s: %w", w.options.ResourceName, err))
+			}
+
+			// Only trust the status once the controller has observed the new spec
+			observed := deployment.Status.ObservedGeneration >= deployment.Generation
+
+			// New pods are ready once every replica runs the updated template and reports ready
+			newPodsReady := observed &&
+				deployment.Status.UpdatedReplicas == deployment.Status.Replicas &&
+				deployment.Status.ReadyReplicas == deployment.Status.Replicas
+
+			// Check if deployment is ready using the standard Kubernetes deployment conditions
+			deploymentReady := false
+			for _, condition := range deployment.Status.Conditions {
+				if condition.Type == "Progressing" && condition.Status == "True" && condition.Reason == "NewReplicaSetAvailable" {
+					deploymentReady = true
+					break
+				}
+			}
+
+			// Also check numeric status fields
+			if !deploymentReady {
+				deploymentReady = newPodsReady &&
+					deployment.Status.AvailableReplicas == deployment.Status.Replicas
+			}
+
+			// Get pods for status information
+			podList, err := w.options.Clientset.CoreV1().Pods(w.options.Namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+			})
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			if err != nil {
+				return fail(fmt.Errorf("failed to list pods for deployment %s: %w", w.options.ResourceName, err))
+			}
+
+			// Events are best effort, the rollout status is still reported without them
+			if err := events.poll(ctx, deployment, podList.Items); err != nil {
+				p.Emit(Event{
+					Event:      EventKubernetesEvent,
+					Namespace:  w.options.Namespace,
+					Deployment: w.options.ResourceName,
+					Message:    fmt.Sprintf("unable to fetch events: %v", err),
+				}, fmt.Sprintf(" %s  Unable to fetch events: %v", p.Icon("⚠️", "[WARN]"), err))
+			}
+
+			// Count pods by status
+			runningPods := 0
+			pendingPods := 0
+			terminatingPods := 0
+			otherPods := 0
+
+			for _, pod := range podList.Items {
+				if pod.DeletionTimestamp != nil {
+					terminatingPods++
+				} else {
+					switch pod.Status.Phase {
+					case "Running":
+						// Check if all containers are ready
+						allContainersReady := true
+						for _, containerStatus := range pod.Status.ContainerStatuses {
+							if !containerStatus.Ready {
+								allContainersReady = false
+								break
+							}
+						}
+						if allContainersReady {
+							runningPods++
+						} else {
+							pendingPods++
+						}
+					case "Pending":
+						pendingPods++
+					default:
+						otherPods++
+					}
+				}
+			}
+
+			desired := deployment.Status.Replicas
+			lastDesired, lastUpdated = desired, deployment.Status.UpdatedReplicas
+			lastReady, lastPending, lastTerminating = runningPods, pendingPods, terminatingPods
+
+			// Fast pipelines only care about the new pods being ready
+			if waitFor == types.WaitForReady && newPodsReady {
+				totalDuration := time.Since(startTime).Round(time.Millisecond)
+				return complete(fmt.Sprintf("Deployment %s new pods are ready (took %v)", w.options.ResourceName, totalDuration),
+					runningPods, terminatingPods, desired)
+			}
+
+			podsReady := deploymentReady && runningPods == int(desired)
+
+			// Availability does not require the old pods to be gone
+			if waitFor == types.WaitForAvailable && podsReady {
+				totalDuration := time.Since(startTime).Round(time.Millisecond)
+				return complete(fmt.Sprintf("Deployment %s successfully rolled out (took %v, %d old pods terminating)",
+					w.options.ResourceName, totalDuration, terminatingPods), runningPods, terminatingPods, desired)
+			}
+
+			// Check if rollout is complete
+			rolloutComplete := podsReady && terminatingPods == 0
+
+			// If deployment is ready but there are still terminating pods, check if we should wait longer
+			if podsReady && terminatingPods > 0 {
+				// Record when deployment became ready
+				if deploymentReadyTime.IsZero() {
+					deploymentReadyTime = time.Now()
+					duration := deploymentReadyTime.Sub(startTime).Round(time.Millisecond)
+					p.Emit(Event{
+						Event:          EventPodsReady,
+						Namespace:      w.options.Namespace,
+						Deployment:     w.options.ResourceName,
+						Desired:        &desired,
+						Ready:          &runningPods,
+						Terminating:    &terminatingPods,
+						ElapsedSeconds: seconds(duration),
+					}, fmt.Sprintf(" %s  New pods are ready (took %v), waiting for old pods cleanup...", p.Icon("✅", "[OK]"), duration))
+				}
+
+				// If we've been waiting for cleanup longer than the grace period, consider it done.
+				// A zero grace period insists on full termination of the old pods.
+				if w.options.CleanupGrace > 0 && time.Since(deploymentReadyTime) > w.options.CleanupGrace {
+					totalDuration := time.Since(startTime).Round(time.Millisecond)
+					cleanupDuration := time.Since(deploymentReadyTime).Round(time.Millisecond)
+					p.Emit(Event{
+						Event:          EventOldPodsTerminating,
+						Namespace:      w.options.Namespace,
+						Deployment:     w.options.ResourceName,
+						Message:        "old pods cleanup taking longer than expected, but deployment is ready",
+						Terminating:    &terminatingPods,
+						CleanupSeconds: seconds(cleanupDuration),
+					}, fmt.Sprintf(" %s  Old pods cleanup taking longer than expected, but deployment is ready", p.Icon("⚠️", "[WARN]")))
+					return complete(fmt.Sprintf("Deployment %s successfully rolled out (took %v total, cleanup %v ongoing)",
+						w.options.ResourceName, totalDuration, cleanupDuration), runningPods, terminatingPods, desired)
+				}
+			}
+
+			// If rollout is complete, exit
+			if rolloutComplete {
+				totalDuration := time.Since(startTime).Round(time.Millisecond)
+				if deploymentReadyTime.IsZero() {
+					return complete(fmt.Sprintf("Deployment %s successfully rolled out (took %v)", w.options.ResourceName, totalDuration),
+						runningPods, terminatingPods, desired)
+				}
+				cleanupDuration := time.Since(deploymentReadyTime).Round(time.Millisecond)
+				return complete(fmt.Sprintf("Deployment %s successfully rolled out (took %v total, cleanup %v)",
+					w.options.ResourceName, totalDuration, cleanupDuration), runningPods, terminatingPods, desired)
+			}
+
+			// Collect details for problematic pods
+			var pods []PodDetail
+			for _, pod := range podList.Items {
+				if pod.Status.Phase != "Running" || pod.DeletionTimestamp != nil {
+					pods = append(pods, describePod(pod))
+				}
+			}
+
+			// Print progress
+			event := Event{
+				Event:          EventProgress,
+				Namespace:      w.options.Namespace,
+				Deployment:     w.options.ResourceName,
+				Desired:        &desired,
+				Ready:          &runningPods,
+				Pending:        &pendingPods,
+				Terminating:    &terminatingPods,
+				ElapsedSeconds: seconds(time.Since(startTime)),
+				Pods:           pods,
+			}
+			if !deploymentReadyTime.IsZero() {
+				event.Event = EventOldPodsTerminating
+			}
+
+			lines := []string{fmt.Sprintf(" %s  Waiting for rollout to finish: %d/%d pods ready, %d pending, %d terminating",
+				p.Icon("⏳", "[WAIT]"), runningPods, desired, pendingPods, terminatingPods)}
+			for _, pod := range pods {
+				lines = append(lines, fmt.Sprintf(" %s  Pod %s status: %s", p.Icon("🔍", "[POD]"), pod.Name, pod.Status))
+				for _, container := range pod.Containers {
+					state := container.State
+					if state == "waiting" {
+						state = "is waiting"
+					}
+					lines = append(lines, fmt.Sprintf("     Container %s %s: %s - %s",
+						container.Name, state, container.Reason, container.Message))
+				}
+			}
+			p.Emit(event, lines...)
+		}
+	}
+}
+
+// describePod returns the status details of a pod that is not running
+func describePod(pod corev1.Pod) PodDetail {
+	detail := PodDetail{
+		Name:   pod.Name,
+		Status: string(pod.Status.Phase),
+	}
+	if pod.DeletionTimestamp != nil {
+		detail.Status = "Terminating"
+	}
+
+	// Collect container statuses for more details
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Ready {
+			continue
+		}
+		if containerStatus.State.Waiting != nil {
+			detail.Containers = append(detail.Containers, ContainerDetail{
+				Name:    containerStatus.Name,
+				State:   "waiting",
+				Reason:  containerStatus.State.Waiting.Reason,
+				Message: containerStatus.State.Waiting.Message,
+			})
+		} else if containerStatus.State.Terminated != nil {
+			detail.Containers = append(detail.Containers, ContainerDetail{
+				Name:    containerStatus.Name,
+				State:   "terminated",
+				Reason:  containerStatus.State.Terminated.Reason,
+				Message: containerStatus.State.Terminated.Message,
+			})
+		}
+	}
+
+	return detail
+}
@@ -0,0 +1,173 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status is a snapshot of a deployment rollout
+type Status struct {
+	Namespace          string `json:"namespace"`
+	Deployment         string `json:"deployment"`
+	Revision           string `json:"revision"`
+	Generation         int64  `json:"generation"`
+	ObservedGeneration int64  `json:"observedGeneration"`
+
+	Desired   int32 `json:"desired"`
+	Updated   int32 `json:"updated"`
+	Ready     int32 `json:"ready"`
+	Available int32 `json:"available"`
+
+	// TargetImages are the images of the deployment's pod template
+	TargetImages []ContainerImage `json:"targetImages"`
+	// CurrentImages are the images of the old ReplicaSets that still have pods
+	CurrentImages []ContainerImage `json:"currentImages,omitempty"`
+
+	ReplicaSets []ReplicaSetStatus `json:"replicaSets"`
+	// StuckPods are the pods that are not running and ready
+	StuckPods []PodDetail `json:"stuckPods,omitempty"`
+
+	Complete bool   `json:"complete"`
+	Message  string `json:"message,omitempty"`
+}
+
+// ContainerImage holds the image of a single container
+type ContainerImage struct {
+	Container string `json:"container"`
+	Image     string `json:"image"`
+}
+
+// ReplicaSetStatus holds the pod counts of a ReplicaSet owned by the deployment
+type ReplicaSetStatus struct {
+	Name      string           `json:"name"`
+	Revision  string           `json:"revision"`
+	New       bool             `json:"new"`
+	Desired   int32            `json:"desired"`
+	Current   int32            `json:"current"`
+	Ready     int32            `json:"ready"`
+	Available int32            `json:"available"`
+	Images    []ContainerImage `json:"images"`
+}
+
+// Status returns a snapshot of the deployment rollout
+func (w *Watcher) Status(ctx context.Context) (*Status, error) {
+	deployment, err := w.options.Clientset.AppsV1().Deployments(w.options.Namespace).Get(ctx, w.options.ResourceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, w.wrapGetError(err)
+	}
+
+	status := &Status{
+		Namespace:          w.options.Namespace,
+		Deployment:         deployment.Name,
+		Revision:           deployment.Annotations[revisionAnnotation],
+		Generation:         deployment.Generation,
+		ObservedGeneration: deployment.Status.ObservedGeneration,
+		Desired:            deployment.Status.Replicas,
+		Updated:            deployment.Status.UpdatedReplicas,
+		Ready:              deployment.Status.ReadyReplicas,
+		Available:          deployment.Status.AvailableReplicas,
+		TargetImages:       containerImages(deployment.Spec.Template.Spec.Containers),
+	}
+	if deployment.Spec.Replicas != nil {
+		status.Desired = *deployment.Spec.Replicas
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing {
+			status.Message = condition.Message
+			status.Complete = condition.Status == corev1.ConditionTrue && condition.Reason == "NewReplicaSetAvailable"
+		}
+	}
+	status.Complete = status.Complete && status.ObservedGeneration >= status.Generation &&
+		status.Updated == status.Desired && status.Available == status.Desired
+
+	rsList, err := w.options.Clientset.AppsV1().ReplicaSets(w.options.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets for deployment %s: %w", deployment.Name, err)
+	}
+
+	for _, rs := range rsList.Items {
+		if !metav1.IsControlledBy(&rs, deployment) {
+			continue
+		}
+
+		rsStatus := ReplicaSetStatus{
+			Name:      rs.Name,
+			Revision:  rs.Annotations[revisionAnnotation],
+			New:       rs.Annotations[revisionAnnotation] == status.Revision,
+			Current:   rs.Status.Replicas,
+			Ready:     rs.Status.ReadyReplicas,
+			Available: rs.Status.AvailableReplicas,
+			Images:    containerImages(rs.Spec.Template.Spec.Containers),
+		}
+		if rs.Spec.Replicas != nil {
+			rsStatus.Desired = *rs.Spec.Replicas
+		}
+
+		// Old ReplicaSets without pods are only kept for rollback history
+		if !rsStatus.New && rsStatus.Current == 0 && rsStatus.Desired == 0 {
+			continue
+		}
+		status.ReplicaSets = append(status.ReplicaSets, rsStatus)
+	}
+
+	// Newest revision first
+	sort.Slice(status.ReplicaSets, func(i, j int) bool {
+		ri, _ := strconv.Atoi(status.ReplicaSets[i].Revision)
+		rj, _ := strconv.Atoi(status.ReplicaSets[j].Revision)
+		return ri > rj
+	})
+
+	// The newest old ReplicaSet still running pods holds the images being replaced
+	for _, rs := range status.ReplicaSets {
+		if !rs.New {
+			status.CurrentImages = rs.Images
+			break
+		}
+	}
+
+	podList, err := w.options.Clientset.CoreV1().Pods(w.options.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for deployment %s: %w", deployment.Name, err)
+	}
+
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || !podReady(pod) {
+			status.StuckPods = append(status.StuckPods, describePod(pod))
+		}
+	}
+
+	return status, nil
+}
+
+// containerImages returns the images of the given containers
+func containerImages(containers []corev1.Container) []ContainerImage {
+	images := make([]ContainerImage, 0, len(containers))
+	for _, container := range containers {
+		images = append(images, ContainerImage{
+			Container: container.Name,
+			Image:     container.Image,
+		})
+	}
+	return images
+}
+
+// podReady reports whether all containers of the pod are ready
+func podReady(pod corev1.Pod) bool {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if !containerStatus.Ready {
+			return false
+		}
+	}
+	return true
+}
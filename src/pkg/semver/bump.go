@@ -0,0 +1,142 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// preReleaseNames are the identifiers treated as pre-releases when bumping.
+// Other pre-release parts such as "alpine" are variant suffixes and are kept.
+var preReleaseNames = []string{"alpha", "beta", "rc", "pre", "preview", "dev", "snapshot", "canary", "nightly"}
+
+// Bump returns the next version for level "major", "minor" or "patch".
+//
+// The prefix and variant suffixes like "-alpine" are kept, v1.2.3-alpine becomes v1.2.4-alpine.
+// With pre, e.g. "rc", the result is the first pre-release of the next version (v1.2.4-rc.1),
+// or the next pre-release when the version already is one (v1.2.4-rc.1 becomes v1.2.4-rc.2).
+// Without pre, a pre-release is released: v1.2.4-rc.2 becomes v1.2.4 for a patch bump.
+func Bump(v *Version, level, pre string) (*Version, error) {
+	next := &Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch, Build: v.Build}
+
+	preName, preNumber, suffix := splitPreRelease(v.PreRelease)
+
+	// Pre-releases already belong to the next version of their level, e.g. 1.3.0-rc.1 for a minor bump
+	released := preName == "" ||
+		(level == "minor" && v.Patch != 0) ||
+		(level == "major" && (v.Minor != 0 || v.Patch != 0))
+
+	switch level {
+	case "major":
+		if released {
+			next.Major++
+		}
+		next.Minor, next.Patch = 0, 0
+	case "minor":
+		if released {
+			next.Minor++
+		}
+		next.Patch = 0
+	case "patch":
+		if released {
+			next.Patch++
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bump level %q (must be one of major, minor, patch)", level)
+	}
+
+	var parts []string
+	if pre != "" {
+		number := 1
+		if !released && strings.EqualFold(preName, pre) {
+			number = preNumber + 1
+		}
+		parts = append(parts, fmt.Sprintf("%s.%d", pre, number))
+	}
+	if suffix != "" {
+		parts = append(parts, suffix)
+	}
+	next.PreRelease = strings.Join(parts, "-")
+	next.Original = next.format(v.Components())
+
+	return next, nil
+}
+
+// splitPreRelease separates a pre-release such as "rc.2-alpine" into its name, number and variant suffix.
+// The name is empty when the pre-release is only a variant suffix.
+func splitPreRelease(preRelease string) (string, int, string) {
+	if preRelease == "" {
+		return "", 0, ""
+	}
+
+	head, suffix, _ := strings.Cut(preRelease, "-")
+	name, numberPart, _ := strings.Cut(head, ".")
+
+	// Accept both "rc.2" and "rc2"
+	letters := strings.TrimRight(name, "0123456789")
+	if numberPart == "" && letters != name {
+		numberPart = name[len(letters):]
+	}
+
+	for _, known := range preReleaseNames {
+		if strings.EqualFold(letters, known) {
+			number, _ := strconv.Atoi(numberPart)
+			return letters, number, suffix
+		}
+	}
+	return "", 0, preRelease
+}
+
+// Suffix returns the variant suffix of the version, e.g. "alpine" for "1.25.3-alpine" or "rc.1-alpine"
+func (v *Version) Suffix() string {
+	_, _, suffix := splitPreRelease(v.PreRelease)
+	return suffix
+}
+
+// withoutSuffix returns the version without its variant suffix, e.g. 1.25.3 for 1.25.3-alpine
+// and 1.3.0-rc.1 for 1.3.0-rc.1-alpine
+func (v *Version) withoutSuffix() *Version {
+	suffix := v.Suffix()
+	if suffix == "" {
+		return v
+	}
+	core := *v
+	core.PreRelease = strings.TrimSuffix(strings.TrimSuffix(v.PreRelease, suffix), "-")
+	return &core
+}
+
+// IsPreRelease reports whether the version is a pre-release such as "1.3.0-rc.1".
+// Variant suffixes like "-alpine" do not make a version a pre-release.
+func (v *Version) IsPreRelease() bool {
+	name, _, _ := splitPreRelease(v.PreRelease)
+	return name != ""
+}
+
+// Components returns the number of version components of the original string, e.g. 2 for "v1.4"
+func (v *Version) Components() int {
+	core := strings.TrimLeft(v.Original, "vV")
+	if i := strings.IndexAny(core, "-+"); i != -1 {
+		core = core[:i]
+	}
+	if core == "" {
+		return 3
+	}
+	return strings.Count(core, ".") + 1
+}
+
+// format renders the version with at least the given number of components,
+// so that "v1.4" is bumped to "v1.5" rather than "v1.5.0"
+func (v *Version) format(components int) string {
+	if components >= 3 || v.Patch != 0 || v.PreRelease != "" {
+		return v.String()
+	}
+
+	s := fmt.Sprintf("%s%d", v.Prefix, v.Major)
+	if components == 2 || v.Minor != 0 {
+		s += fmt.Sprintf(".%d", v.Minor)
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
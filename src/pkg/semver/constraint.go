@@ -0,0 +1,238 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a version range such as "~1.4", "^2", ">=1.2 <1.5" or "1.x || 2.x".
+// Whitespace or comma separated comparators must all match, "||" separates alternatives.
+type Constraint struct {
+	alternatives [][]comparator
+	original     string
+}
+
+// comparator is a single operator and bound, e.g. ">=1.4.0"
+type comparator struct {
+	op      string
+	version *Version
+}
+
+// ParseConstraint parses a version constraint, an empty string or "*" matches any version
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(alternative, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t'
+		})
+
+		// Join operators written apart from their version, e.g. ">= 1.2"
+		var terms []string
+		for i := 0; i < len(fields); i++ {
+			if strings.Trim(fields[i], "<>=!~^") == "" && i+1 < len(fields) {
+				terms = append(terms, fields[i]+fields[i+1])
+				i++
+				continue
+			}
+			terms = append(terms, fields[i])
+		}
+
+		var comparators []comparator
+		for _, term := range terms {
+			expanded, err := parseTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			comparators = append(comparators, expanded...)
+		}
+		c.alternatives = append(c.alternatives, comparators)
+	}
+
+	return c, nil
+}
+
+// String returns the constraint as it was given
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// Check reports whether the version satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, comparators := range c.alternatives {
+		matched := true
+		for _, cmp := range comparators {
+			if !cmp.check(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// check compares the version against the bound. Variant suffixes are left to the tag
+// selection, so "~1.25" matches 1.25.3-alpine like 1.25.3.
+func (c comparator) check(v *Version) bool {
+	result := v.withoutSuffix().Compare(c.version)
+	switch c.op {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case "!=":
+		return result != 0
+	default:
+		return result == 0
+	}
+}
+
+// parseTerm expands a single term into comparators with full versions
+func parseTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			term = term[len(candidate):]
+			break
+		}
+	}
+
+	p, err := parsePartial(term)
+	if err != nil {
+		return nil, err
+	}
+	if p.parts == 0 {
+		// "*", "x" and friends match everything but != and < bounds
+		switch op {
+		case "", "=", ">=", "<=", "~", "^":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%s%s matches no version", op, term)
+		}
+	}
+
+	lower := p.version()
+	switch op {
+	case "", "=":
+		if p.parts == 3 {
+			return []comparator{{"=", lower}}, nil
+		}
+		return []comparator{{">=", lower}, {"<", p.next(p.parts - 1)}}, nil
+	case "!=":
+		if p.parts == 3 {
+			return []comparator{{"!=", lower}}, nil
+		}
+		return nil, fmt.Errorf("!= requires a full version")
+	case ">=":
+		return []comparator{{op, lower}}, nil
+	case "<":
+		// <1.5.0 does not include the pre-releases of 1.5.0
+		if lower.PreRelease == "" {
+			lower.PreRelease = "0"
+		}
+		return []comparator{{op, lower}}, nil
+	case ">":
+		if p.parts == 3 {
+			return []comparator{{">", lower}}, nil
+		}
+		// >1.4 means above any 1.4.x
+		return []comparator{{">=", p.next(p.parts - 1)}}, nil
+	case "<=":
+		if p.parts == 3 {
+			return []comparator{{"<=", lower}}, nil
+		}
+		return []comparator{{"<", p.next(p.parts - 1)}}, nil
+	case "~":
+		// ~1.4.2 allows patch updates, ~1 allows minor updates
+		if p.parts == 1 {
+			return []comparator{{">=", lower}, {"<", p.next(0)}}, nil
+		}
+		return []comparator{{">=", lower}, {"<", p.next(1)}}, nil
+	case "^":
+		// ^ allows updates that do not change the left-most non-zero component
+		switch {
+		case p.major > 0 || p.parts == 1:
+			return []comparator{{">=", lower}, {"<", p.next(0)}}, nil
+		case p.minor > 0 || p.parts == 2:
+			return []comparator{{">=", lower}, {"<", p.next(1)}}, nil
+		default:
+			return []comparator{{">=", lower}, {"<", p.next(2)}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+// partial is a version that may omit minor and patch, e.g. "1.4" or "1.4.x"
+type partial struct {
+	major, minor, patch uint64
+	// parts is the number of components given, wildcards end the version
+	parts      int
+	preRelease string
+}
+
+// parsePartial parses a possibly incomplete version
+func parsePartial(s string) (*partial, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	p := &partial{}
+
+	if plus := strings.Index(s, "+"); plus != -1 {
+		s = s[:plus]
+	}
+	if dash := strings.Index(s, "-"); dash != -1 {
+		p.preRelease = s[dash+1:]
+		s = s[:dash]
+	}
+
+	numbers := []*uint64{&p.major, &p.minor, &p.patch}
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return nil, fmt.Errorf("too many components in %q", s)
+	}
+	for i, component := range components {
+		if component == "*" || component == "x" || component == "X" || (i == 0 && component == "") {
+			break
+		}
+		n, err := strconv.ParseUint(component, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+		p.parts++
+	}
+
+	if p.preRelease != "" && p.parts != 3 {
+		return nil, fmt.Errorf("pre-release requires a full version in %q", s)
+	}
+	return p, nil
+}
+
+// version returns the lowest version matching the partial version, without variant suffix
+func (p *partial) version() *Version {
+	v := &Version{Major: p.major, Minor: p.minor, Patch: p.patch, PreRelease: p.preRelease}
+	return v.withoutSuffix()
+}
+
+// next returns the exclusive upper bound when incrementing the component at index.
+// The bound has the lowest pre-release so pre-releases of the next version are excluded.
+func (p *partial) next(index int) *Version {
+	v := &Version{PreRelease: "0"}
+	switch index {
+	case 0:
+		v.Major = p.major + 1
+	case 1:
+		v.Major, v.Minor = p.major, p.minor+1
+	default:
+		v.Major, v.Minor, v.Patch = p.major, p.minor, p.patch+1
+	}
+	return v
+}
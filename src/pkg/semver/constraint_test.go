@@ -0,0 +1,65 @@
+package semver
+
+import "testing"
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "*", version: "1.2.3", want: true},
+		{constraint: "~1.4", version: "1.4.7", want: true},
+		{constraint: "~1.4", version: "1.5.0"},
+		{constraint: "~1.4", version: "1.5.0-rc.1"},
+		{constraint: "^2", version: "2.9.0", want: true},
+		{constraint: "^2", version: "3.0.0"},
+		{constraint: "^0.3", version: "0.3.9", want: true},
+		{constraint: "^0.3", version: "0.4.0"},
+		{constraint: ">=1.2 <1.5", version: "1.4.9", want: true},
+		{constraint: ">=1.2, <1.5", version: "1.5.0"},
+		{constraint: ">= 1.2", version: "1.2.0", want: true},
+		{constraint: "1.x || 3.x", version: "3.1.0", want: true},
+		{constraint: "1.x || 3.x", version: "2.1.0"},
+		{constraint: "!=1.4.2", version: "1.4.2"},
+		{constraint: "v1.4", version: "v1.4.1", want: true},
+
+		// Variant suffixes are not pre-releases, the version core is compared
+		{constraint: "~1.25", version: "1.25.0-alpine", want: true},
+		{constraint: "^1.25", version: "1.27.3-slim", want: true},
+		{constraint: "~1.25", version: "1.26.0-alpine"},
+		{constraint: "=1.25.3", version: "1.25.3-alpine", want: true},
+		{constraint: "=1.25.3-alpine", version: "1.25.3-alpine", want: true},
+		{constraint: "=1.25.3-alpine", version: "1.25.3", want: true},
+		{constraint: ">1.25.3", version: "1.25.3-alpine"},
+		{constraint: "<1.26", version: "1.25.9-alpine", want: true},
+		{constraint: "<1.26", version: "1.26.0-alpine"},
+		{constraint: "~1.25", version: "1.25.1-rc.1-alpine", want: true},
+		{constraint: ">=1.25.1-rc.1", version: "1.25.1-rc.2-alpine", want: true},
+		{constraint: "<1.26", version: "1.26.0-rc.1-alpine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint: %v", err)
+			}
+			v, err := Parse(tt.version)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := c.Check(v); got != tt.want {
+				t.Errorf("Check = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, constraint := range []string{"~1.a", "!=1.4", ">*", "1.2.3.4", "=1.2-rc.1"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("constraint %q was accepted, want an error", constraint)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version as found in image tags.
+// A leading "v" is accepted and preserved, minor and patch may be omitted.
+type Version struct {
+	Prefix     string
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	PreRelease string
+	Build      string
+
+	// Original is the string the version was parsed from
+	Original string
+}
+
+// Parse parses a semantic version such as "v1.2.3-rc.1+build.5"
+func Parse(s string) (*Version, error) {
+	v := &Version{Original: s}
+	rest := s
+
+	if strings.HasPrefix(rest, "v") || strings.HasPrefix(rest, "V") {
+		v.Prefix = rest[:1]
+		rest = rest[1:]
+	}
+
+	if plus := strings.Index(rest, "+"); plus != -1 {
+		v.Build = rest[plus+1:]
+		rest = rest[:plus]
+		if v.Build == "" {
+			return nil, fmt.Errorf("invalid semantic version %q: empty build metadata", s)
+		}
+	}
+
+	if dash := strings.Index(rest, "-"); dash != -1 {
+		v.PreRelease = rest[dash+1:]
+		rest = rest[:dash]
+		if v.PreRelease == "" {
+			return nil, fmt.Errorf("invalid semantic version %q: empty pre-release", s)
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid semantic version %q: too many components", s)
+	}
+
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if part == "" || (len(part) > 1 && part[0] == '0') {
+			return nil, fmt.Errorf("invalid semantic version %q", s)
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semantic version %q", s)
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// String returns the version with its original prefix
+func (v *Version) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or higher than o.
+// Build metadata is ignored as required by the specification.
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePreRelease(v.PreRelease, o.PreRelease)
+}
+
+// LessThan reports whether v is lower than o
+func (v *Version) LessThan(o *Version) bool {
+	return v.Compare(o) < 0
+}
+
+// compareUint compares two numbers
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePreRelease compares pre-release identifiers, a release is higher than any pre-release
+func comparePreRelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bParts[i], 10, 64)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareUint(aNum, bNum); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareUint(uint64(len(aParts)), uint64(len(bParts)))
+}
@@ -0,0 +1,113 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setDeploymentImages updates the images of several containers of a deployment at once,
+// so a promotion or lock file triggers a single rollout
+func (s *ImageSetter) setDeploymentImages(ctx context.Context) (*Result, error) {
+	deploymentsClient := s.options.Clientset.AppsV1().Deployments(s.options.Namespace)
+
+	deployment, err := deploymentsClient.Get(ctx, s.options.ResourceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, s.wrapGetError(err)
+	}
+
+	containers := deployment.Spec.Template.Spec.Containers
+	found := make(map[string]bool, len(s.options.ContainerImages))
+	for i := range containers {
+		if _, exists := s.options.ContainerImages[containers[i].Name]; exists {
+			found[containers[i].Name] = true
+		}
+	}
+	for name := range s.options.ContainerImages {
+		if !found[name] {
+			return nil, &types.ContainerNotFoundError{
+				Container:    name,
+				ResourceType: string(types.ResourceTypeDeployment),
+				ResourceName: s.options.ResourceName,
+			}
+		}
+	}
+
+	result := &Result{
+		Namespace:    s.options.Namespace,
+		ResourceType: types.ResourceTypeDeployment,
+		ResourceName: s.options.ResourceName,
+		DryRun:       s.options.DryRun,
+	}
+	for i := range containers {
+		newImage, exists := s.options.ContainerImages[containers[i].Name]
+		if !exists || newImage == containers[i].Image {
+			continue
+		}
+		if err := s.checkPolicy(ctx, policy.Target{
+			Namespace: s.options.Namespace,
+			Container: containers[i].Name,
+			OldImage:  containers[i].Image,
+			NewImage:  newImage,
+			Workload:  deploymentWorkload(deployment),
+		}); err != nil {
+			return nil, err
+		}
+		var verification *Verification
+		if s.options.VerifyImage {
+			verification, err = s.verifyImage(ctx, newImage, &deployment.Spec.Template.Spec)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Changes = append(result.Changes, Change{
+			Container:    containers[i].Name,
+			OldImage:     containers[i].Image,
+			NewImage:     newImage,
+			Verification: verification,
+		})
+	}
+
+	if len(result.Changes) == 0 || s.options.DryRun {
+		return result, nil
+	}
+
+	if err := s.confirm(result.Changes); err != nil {
+		return nil, err
+	}
+
+	for _, change := range result.Changes {
+		for i := range containers {
+			if containers[i].Name == change.Container {
+				containers[i].Image = change.NewImage
+			}
+		}
+	}
+
+	_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update deployment %s: %w", s.options.ResourceName, err)
+	}
+
+	for i, change := range result.Changes {
+		lines := []string{fmt.Sprintf("Updating container %s image from %s to %s", change.Container, change.OldImage, change.NewImage)}
+		if i == len(result.Changes)-1 {
+			lines = append(lines, fmt.Sprintf("deployment.apps/%s image updated", s.options.ResourceName))
+		}
+		s.progress.Emit(rollout.Event{
+			Event:      rollout.EventImageUpdated,
+			Namespace:  s.options.Namespace,
+			Deployment: s.options.ResourceName,
+			Container:  change.Container,
+			OldImage:   change.OldImage,
+			NewImage:   change.NewImage,
+		}, lines...)
+	}
+
+	return result, nil
+}
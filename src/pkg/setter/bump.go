@@ -0,0 +1,39 @@
+package setter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// bumpTag returns the next version of the image's tag for the requested bump level
+func (s *ImageSetter) bumpTag(image string) (string, error) {
+	current := getter.ExtractTag(image)
+	version, err := semver.Parse(current)
+	if err != nil {
+		return "", &types.InvalidReferenceError{
+			Reference: image,
+			Reason:    fmt.Sprintf("tag %q is not a semantic version and cannot be bumped", current),
+		}
+	}
+
+	level := types.ValidBumpLevels[strings.ToLower(s.options.BumpLevel)]
+	next, err := semver.Bump(version, string(level), s.options.BumpPreRelease)
+	if err != nil {
+		return "", err
+	}
+
+	s.progress.Emit(rollout.Event{
+		Event:      rollout.EventTagResolved,
+		Namespace:  s.options.Namespace,
+		Deployment: s.options.ResourceName,
+		Message:    fmt.Sprintf("%s bump of %s", level, current),
+		Tag:        next.Original,
+	}, fmt.Sprintf("Bumping %s version: %s -> %s", level, current, next.Original))
+
+	return next.Original, nil
+}
@@ -0,0 +1,64 @@
+package setter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// confirm asks before a deployment is updated. Deployments of protected contexts and namespaces
+// need their name typed unless --yes is given, other deployments a y/N answer with --confirm.
+func (s *ImageSetter) confirm(changes []Change) error {
+	protected := s.cfg.Protected.Matches(s.options.Context, s.options.Namespace)
+	if s.options.Yes || (!protected && !s.options.Confirm) {
+		return nil
+	}
+
+	out := s.options.ErrOut
+	if out == nil {
+		out = io.Discard
+	}
+	if s.options.In == nil {
+		return fmt.Errorf("deployment %s needs confirmation, pass --yes to update it without a prompt", s.options.ResourceName)
+	}
+
+	fmt.Fprintf(out, "deployment.apps/%s in %s:\n", s.options.ResourceName, s.target())
+	for _, change := range changes {
+		fmt.Fprintf(out, "  container %s:\n", change.Container)
+		fmt.Fprintf(out, "    - %s\n", change.OldImage)
+		fmt.Fprintf(out, "    + %s\n", change.NewImage)
+	}
+
+	if protected {
+		fmt.Fprintf(out, "%s is protected. Type the deployment name to update it: ", s.target())
+	} else {
+		fmt.Fprint(out, "Update the deployment? [y/N]: ")
+	}
+	answer, err := s.options.In.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" && errors.Is(err, io.EOF) {
+		// Nobody can answer, e.g. in CI
+		fmt.Fprintln(out)
+		return fmt.Errorf("deployment %s needs confirmation, pass --yes to update it without a prompt", s.options.ResourceName)
+	}
+
+	switch {
+	case protected && answer != s.options.ResourceName:
+		return fmt.Errorf("%q does not match the deployment name %s, nothing was changed", answer, s.options.ResourceName)
+	case !protected && !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes"):
+		return fmt.Errorf("update of deployment %s cancelled", s.options.ResourceName)
+	}
+	return nil
+}
+
+// target describes the cluster and namespace being updated, e.g. "prod-eu/payments"
+func (s *ImageSetter) target() string {
+	if s.options.Context == "" {
+		return "namespace " + s.options.Namespace
+	}
+	return s.options.Context + "/" + s.options.Namespace
+}
@@ -0,0 +1,68 @@
+package setter
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+func TestConfirmSharesPipedAnswers(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("y\napp\n"))
+	cfg := &config.Config{Protected: config.Protected{Namespaces: []string{"prod"}}}
+	changes := []Change{{Container: "app", OldImage: "app:1.0", NewImage: "app:1.1"}}
+
+	// Every prompt reads its own line of the same input
+	for _, namespace := range []string{"dev", "prod"} {
+		s := New(&types.Options{
+			ResourceName: "app",
+			Namespace:    namespace,
+			Confirm:      true,
+			In:           in,
+			ErrOut:       io.Discard,
+		}, cfg)
+		if err := s.confirm(changes); err != nil {
+			t.Fatalf("namespace %s: %v", namespace, err)
+		}
+	}
+
+	s := New(&types.Options{ResourceName: "app", Namespace: "dev", Confirm: true, In: in, ErrOut: io.Discard}, cfg)
+	if err := s.confirm(changes); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("prompt after the last answer = %v, want a --yes error", err)
+	}
+}
+
+func TestConfirmAnswers(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		answer    string
+		wantErr   bool
+	}{
+		{name: "yes", namespace: "dev", answer: "y\n"},
+		{name: "yes in full", namespace: "dev", answer: "YES\n"},
+		{name: "no", namespace: "dev", answer: "n\n", wantErr: true},
+		{name: "no answer", namespace: "dev", answer: "", wantErr: true},
+		{name: "protected name", namespace: "prod", answer: "app\n"},
+		{name: "protected yes", namespace: "prod", answer: "y\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(&types.Options{
+				ResourceName: "app",
+				Namespace:    tt.namespace,
+				Confirm:      true,
+				In:           bufio.NewReader(strings.NewReader(tt.answer)),
+				ErrOut:       io.Discard,
+			}, &config.Config{Protected: config.Protected{Namespaces: []string{"prod"}}})
+			err := s.confirm([]Change{{Container: "app", OldImage: "app:1.0", NewImage: "app:1.1"}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("confirm = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
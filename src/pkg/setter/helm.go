@@ -0,0 +1,67 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/helm"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SetHelmValues updates the image at --path of a Helm values file instead of the cluster.
+// The values are changed in memory, saving them is up to the caller.
+func (s *ImageSetter) SetHelmValues(ctx context.Context, values *helm.Values) (*Result, error) {
+	current, err := values.Image(s.options.ValuesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	oldImage := current.Image
+	newImage, err := s.resolveNewImage(ctx, oldImage, &corev1.PodSpec{})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPolicy(ctx, policy.Target{Namespace: s.options.Namespace, OldImage: oldImage, NewImage: newImage}); err != nil {
+		return nil, err
+	}
+
+	var verification *Verification
+	if s.options.VerifyImage {
+		verification, err = s.verifyImage(ctx, newImage, &corev1.PodSpec{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		ResourceName: current.Path,
+		OldImage:     oldImage,
+		NewImage:     newImage,
+		Verification: verification,
+		Changes:      []Change{{Container: current.Path, OldImage: oldImage, NewImage: newImage, Verification: verification}},
+		Source:       values.File.Path,
+		DryRun:       s.options.DryRun,
+	}
+	if s.options.DryRun || newImage == oldImage {
+		return result, nil
+	}
+
+	if err := values.SetImage(current.Path, newImage); err != nil {
+		return nil, err
+	}
+
+	s.progress.Emit(rollout.Event{
+		Event:    rollout.EventImageUpdated,
+		OldImage: oldImage,
+		NewImage: newImage,
+		Message:  "values " + values.File.Path,
+	},
+		fmt.Sprintf("Updating %s image from %s to %s", current.Path, oldImage, newImage),
+		fmt.Sprintf("%s updated", values.File.Path),
+	)
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/kustomize"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SetKustomization updates the images entry of --kustomize for the image name instead of the cluster.
+// The kustomization is changed in memory, saving it is up to the caller.
+func (s *ImageSetter) SetKustomization(ctx context.Context, k *kustomize.Kustomization) (*Result, error) {
+	effective, err := k.Effective(s.options.ImageName)
+	if err != nil {
+		return nil, err
+	}
+	if len(effective) > 1 && s.options.Image == "" {
+		return nil, fmt.Errorf("image %s resolves to different images in %s: %s, give the full image instead of a tag",
+			s.options.ImageName, k.Dir, strings.Join(effective, ", "))
+	}
+
+	oldImage := effective[0]
+	newImage, err := s.resolveNewImage(ctx, oldImage, &corev1.PodSpec{})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPolicy(ctx, policy.Target{Namespace: s.options.Namespace, OldImage: oldImage, NewImage: newImage}); err != nil {
+		return nil, err
+	}
+
+	var verification *Verification
+	if s.options.VerifyImage {
+		verification, err = s.verifyImage(ctx, newImage, &corev1.PodSpec{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		ResourceName: s.options.ImageName,
+		OldImage:     oldImage,
+		NewImage:     newImage,
+		Verification: verification,
+		Changes:      []Change{{Container: s.options.ImageName, OldImage: oldImage, NewImage: newImage, Verification: verification}},
+		Source:       k.File.Path,
+		DryRun:       s.options.DryRun,
+	}
+	if s.options.DryRun {
+		return result, nil
+	}
+
+	if err := k.SetImage(s.options.ImageName, newImage); err != nil {
+		return nil, err
+	}
+
+	s.progress.Emit(rollout.Event{
+		Event:    rollout.EventImageUpdated,
+		OldImage: oldImage,
+		NewImage: newImage,
+		Message:  "kustomization " + k.File.Path,
+	},
+		fmt.Sprintf("Updating image %s from %s to %s", s.options.ImageName, oldImage, newImage),
+		fmt.Sprintf("%s updated", k.File.Path),
+	)
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/tags"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// resolveLatestTag returns the highest registry tag of the image's repository matching the --latest constraint
+func (s *ImageSetter) resolveLatestTag(ctx context.Context, image string, podSpec *corev1.PodSpec) (string, error) {
+	selector, err := tags.NewSelector(s.options.LatestConstraint, s.options.TagPattern, s.options.IncludePreRelease)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := reference.Parse(stripTag(image))
+	if err != nil {
+		return "", &types.InvalidReferenceError{Reference: image, Reason: err.Error()}
+	}
+	// Stay on the variant of the current tag, e.g. "-alpine"
+	if current, err := reference.Parse(image); err == nil && current.Tag != "" {
+		selector.MatchVariant(current.Tag)
+	}
+
+	client := registry.New(s.options)
+	client.UsePodSpecPullSecrets(podSpec)
+	names, err := client.ListTags(ctx, ref)
+	if err != nil {
+		if registry.IsNotFound(err) {
+			return "", &types.ImageNotFoundError{Image: ref.Name(), Err: err}
+		}
+		return "", fmt.Errorf("failed to list tags of %s: %w", ref.Name(), err)
+	}
+
+	tag, err := selector.Select(names)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve latest tag of %s: %w", ref.Name(), err)
+	}
+
+	constraint := s.options.LatestConstraint
+	if constraint == "" {
+		constraint = "*"
+	}
+	s.progress.Emit(rollout.Event{
+		Event:      rollout.EventTagResolved,
+		Namespace:  s.options.Namespace,
+		Deployment: s.options.ResourceName,
+		Message:    fmt.Sprintf("latest tag matching %s", constraint),
+		Tag:        tag,
+	}, fmt.Sprintf("Resolved latest tag of %s matching %s: %s", ref.Name(), constraint, tag))
+
+	return tag, nil
+}
@@ -0,0 +1,121 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/manifest"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SetManifest updates the image of the specified resource in local manifest files instead of the cluster.
+// The files are changed in memory, saving them is up to the caller.
+func (s *ImageSetter) SetManifest(ctx context.Context, files []*manifest.File) (*Result, error) {
+	resourceType, exists := types.ValidResourceTypes[strings.ToLower(s.options.ResourceType)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported resource type: %s", s.options.ResourceType)
+	}
+
+	workload, err := manifest.Find(files, types.ResourceKinds[resourceType], s.options.ResourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := s.manifestContainer(resourceType, workload.PodSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	oldImage := container.Image
+	newImage, err := s.resolveNewImage(ctx, oldImage, workload.PodSpec)
+	if err != nil {
+		return nil, err
+	}
+	// -n names the namespace of manifests that leave it to kubectl apply
+	namespace := workload.Namespace
+	if namespace == "" {
+		namespace = s.options.Namespace
+	}
+	if err := s.checkPolicy(ctx, policy.Target{
+		Namespace: namespace,
+		Container: container.Name,
+		OldImage:  oldImage,
+		NewImage:  newImage,
+		Workload: policy.Workload{
+			Kind:        workload.Kind,
+			Name:        workload.Name,
+			Namespace:   workload.Namespace,
+			Labels:      workload.Labels,
+			Annotations: workload.Annotations,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	var verification *Verification
+	if s.options.VerifyImage {
+		verification, err = s.verifyImage(ctx, newImage, workload.PodSpec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		Namespace:    workload.Namespace,
+		ResourceType: resourceType,
+		ResourceName: s.options.ResourceName,
+		Container:    container.Name,
+		OldImage:     oldImage,
+		NewImage:     newImage,
+		Verification: verification,
+		Changes:      []Change{{Container: container.Name, OldImage: oldImage, NewImage: newImage, Verification: verification}},
+		Source:       workload.File.Path,
+		DryRun:       s.options.DryRun,
+	}
+	if s.options.DryRun || newImage == oldImage {
+		return result, nil
+	}
+
+	if err := workload.SetImage(container.Name, newImage); err != nil {
+		return nil, err
+	}
+
+	source := workload.File.Path
+	if source == "-" {
+		source = "stdin"
+	}
+	s.progress.Emit(rollout.Event{
+		Event:      rollout.EventImageUpdated,
+		Namespace:  workload.Namespace,
+		Deployment: s.options.ResourceName,
+		Container:  container.Name,
+		OldImage:   oldImage,
+		NewImage:   newImage,
+		Message:    "manifest " + source,
+	},
+		fmt.Sprintf("Updating container %s image from %s to %s", container.Name, oldImage, newImage),
+		fmt.Sprintf("%s/%s image updated in %s", resourceType, s.options.ResourceName, source),
+	)
+
+	return result, nil
+}
+
+// manifestContainer returns the container to update, the first one by default
+func (s *ImageSetter) manifestContainer(resourceType types.ResourceType, podSpec *corev1.PodSpec) (*corev1.Container, error) {
+	for i := range podSpec.Containers {
+		if s.options.ContainerName == "" || podSpec.Containers[i].Name == s.options.ContainerName {
+			return &podSpec.Containers[i], nil
+		}
+	}
+
+	return nil, &types.ContainerNotFoundError{
+		Container:    s.options.ContainerName,
+		ResourceType: string(resourceType),
+		ResourceName: s.options.ResourceName,
+	}
+}
@@ -0,0 +1,138 @@
+package setter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Verification is the result of resolving the new image in its registry
+type Verification struct {
+	Digest string
+	// Platforms is empty for single platform images
+	Platforms []string
+	// MissingArchitectures lists node architectures the image is not built for
+	MissingArchitectures []string
+}
+
+// verifyImage resolves the new image against its registry and refuses images that do not exist
+func (s *ImageSetter) verifyImage(ctx context.Context, image string, podSpec *corev1.PodSpec) (*Verification, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return nil, &types.InvalidReferenceError{Reference: image, Reason: err.Error()}
+	}
+
+	client := registry.New(s.options)
+	client.UsePodSpecPullSecrets(podSpec)
+	digest, err := client.Resolve(ctx, ref)
+	if err != nil {
+		if registry.IsNotFound(err) {
+			return nil, &types.ImageNotFoundError{Image: image, Err: err}
+		}
+		return nil, fmt.Errorf("failed to verify image %s: %w", image, err)
+	}
+
+	verification := &Verification{Digest: digest}
+
+	manifest, err := client.GetManifest(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest of %s: %w", image, err)
+	}
+
+	architectures := make(map[string]bool)
+	for _, platform := range manifest.Platforms() {
+		verification.Platforms = append(verification.Platforms, platform.String())
+		if platform.OS == "linux" {
+			architectures[platform.Architecture] = true
+		}
+	}
+
+	// Single platform images do not list their platform in the manifest,
+	// and node architectures are unknown without a cluster
+	if len(architectures) > 0 && s.options.Clientset != nil {
+		nodeArchitectures, err := s.nodeArchitectures(ctx, podSpec)
+		if err != nil {
+			s.progress.Emit(rollout.Event{
+				Event:      rollout.EventImageVerified,
+				Namespace:  s.options.Namespace,
+				Deployment: s.options.ResourceName,
+				Message:    fmt.Sprintf("unable to check node architectures: %v", err),
+			}, fmt.Sprintf(" %s  Unable to check node architectures: %v", s.progress.Icon("⚠️", "[WARN]"), err))
+		}
+		for _, arch := range nodeArchitectures {
+			if !architectures[arch] {
+				verification.MissingArchitectures = append(verification.MissingArchitectures, arch)
+			}
+		}
+	}
+
+	lines := []string{fmt.Sprintf("Verified image %s (digest %s)", image, digest)}
+	if len(verification.Platforms) > 0 {
+		lines = append(lines, fmt.Sprintf("  platforms: %s", strings.Join(verification.Platforms, ", ")))
+	}
+	if len(verification.MissingArchitectures) > 0 {
+		lines = append(lines, fmt.Sprintf(" %s  Image is not built for node architecture(s): %s",
+			s.progress.Icon("⚠️", "[WARN]"), strings.Join(verification.MissingArchitectures, ", ")))
+	}
+	s.progress.Emit(rollout.Event{
+		Event:      rollout.EventImageVerified,
+		Namespace:  s.options.Namespace,
+		Deployment: s.options.ResourceName,
+		NewImage:   image,
+		Digest:     digest,
+		Platforms:  verification.Platforms,
+		Warnings:   missingArchitectureWarnings(verification.MissingArchitectures),
+	}, lines...)
+
+	return verification, nil
+}
+
+// nodeArchitectures returns the architectures of the nodes the pods can be scheduled on
+func (s *ImageSetter) nodeArchitectures(ctx context.Context, podSpec *corev1.PodSpec) ([]string, error) {
+	nodeList, err := s.options.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(podSpec.NodeSelector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		arch := node.Status.NodeInfo.Architecture
+		if arch == "" {
+			arch = node.Labels[corev1.LabelArchStable]
+		}
+		if arch != "" {
+			seen[arch] = true
+		}
+	}
+
+	architectures := make([]string, 0, len(seen))
+	for arch := range seen {
+		architectures = append(architectures, arch)
+	}
+	sort.Strings(architectures)
+	return architectures, nil
+}
+
+// missingArchitectureWarnings formats the architecture warnings for the progress stream
+func missingArchitectureWarnings(architectures []string) []string {
+	warnings := make([]string, 0, len(architectures))
+	for _, arch := range architectures {
+		warnings = append(warnings, fmt.Sprintf("image is not built for node architecture %s", arch))
+	}
+	return warnings
+}
@@ -3,299 +3,321 @@ package setter
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
-	"time"
 
+	"github.com/reedchan7/kubectl-image/src/pkg/config"
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/rollout"
 	"github.com/reedchan7/kubectl-image/src/pkg/types"
+	"github.com/reedchan7/kubectl-image/src/pkg/validator"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ImageSetter handles image updates for Kubernetes resources
 type ImageSetter struct {
-	options *types.Options
+	options  *types.Options
+	progress *rollout.Printer
+
+	// cfg is the configuration file with the policies and protected targets
+	cfg *config.Config
 }
 
-// New creates a new ImageSetter
-func New(options *types.Options) *ImageSetter {
+// New creates a new ImageSetter. The configuration is loaded once by the command,
+// nil stands for an empty one.
+func New(options *types.Options, cfg *config.Config) *ImageSetter {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
 	return &ImageSetter{
-		options: options,
+		options:  options,
+		progress: rollout.NewPrinter(options),
+		cfg:      cfg,
 	}
 }
 
+// Result describes the outcome of an image update
+type Result struct {
+	Namespace    string
+	ResourceType types.ResourceType
+	ResourceName string
+	Container    string
+	OldImage     string
+	NewImage     string
+
+	// Verification is only set when the new image of a single container was verified
+	// against its registry, see Changes for each container of a multi-container update
+	Verification *Verification
+
+	// Rollout is only set when waiting for the rollout was requested
+	Rollout *rollout.Result
+
+	// Changes lists every container whose image was set, in spec order
+	Changes []Change
+
+	// Source is the manifest file that was changed instead of the cluster
+	Source string
+
+	// DryRun is true when the resource was left unchanged
+	DryRun bool
+}
+
+// Change is the image update of a single container
+type Change struct {
+	Container string
+	OldImage  string
+	NewImage  string
+
+	// Verification is only set when the new image was verified against its registry
+	Verification *Verification `json:",omitempty"`
+}
+
 // Set updates the image of the specified resource
-func (s *ImageSetter) Set() error {
+func (s *ImageSetter) Set(ctx context.Context) (*Result, error) {
 	resourceType, exists := types.ValidResourceTypes[strings.ToLower(s.options.ResourceType)]
 	if !exists {
-		return fmt.Errorf("unsupported resource type: %s", s.options.ResourceType)
+		return nil, fmt.Errorf("unsupported resource type: %s", s.options.ResourceType)
 	}
 
 	switch resourceType {
 	case types.ResourceTypeDeployment:
-		err := s.setDeploymentImage()
+		var result *Result
+		var err error
+		if len(s.options.ContainerImages) > 0 {
+			result, err = s.setDeploymentImages(ctx)
+		} else {
+			result, err = s.setDeploymentImage(ctx)
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// If wait flag is set, wait for rollout to complete
-		if s.options.Wait {
-			return s.waitForDeploymentRollout()
+		if s.options.Wait && !s.options.DryRun {
+			rolloutResult, err := rollout.New(s.options).Wait(ctx)
+			if err != nil {
+				return result, err
+			}
+			result.Rollout = rolloutResult
 		}
-		return nil
+		return result, nil
 	case types.ResourceTypePod:
-		return fmt.Errorf("direct pod image update is not supported - pods are immutable. Please update the deployment or other controller instead")
+		return nil, fmt.Errorf("direct pod image update is not supported - pods are immutable. Please update the deployment or other controller instead")
 	default:
-		return fmt.Errorf("unsupported resource type: %s", s.options.ResourceType)
+		return nil, fmt.Errorf("unsupported resource type: %s", s.options.ResourceType)
 	}
 }
 
 // setDeploymentImage updates the image of a deployment
-func (s *ImageSetter) setDeploymentImage() error {
-	ctx := context.TODO()
+func (s *ImageSetter) setDeploymentImage(ctx context.Context) (*Result, error) {
 	deploymentsClient := s.options.Clientset.AppsV1().Deployments(s.options.Namespace)
 
 	// Get the deployment
 	deployment, err := deploymentsClient.Get(ctx, s.options.ResourceName, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to get deployment %s: %v", s.options.ResourceName, err)
+		return nil, s.wrapGetError(err)
 	}
 
-	updated := false
+	var container *corev1.Container
 
 	if s.options.ContainerName != "" {
 		// Update specific container
 		for i := range deployment.Spec.Template.Spec.Containers {
-			container := &deployment.Spec.Template.Spec.Containers[i]
-			if container.Name == s.options.ContainerName {
-				newImage := s.getNewImageForContainer(container.Image)
-				fmt.Printf("Updating container %s image from %s to %s\n", container.Name, container.Image, newImage)
-				container.Image = newImage
-				updated = true
+			if deployment.Spec.Template.Spec.Containers[i].Name == s.options.ContainerName {
+				container = &deployment.Spec.Template.Spec.Containers[i]
 				break
 			}
 		}
 
-		if !updated {
-			return fmt.Errorf("container %s not found in deployment %s", s.options.ContainerName, s.options.ResourceName)
+		if container == nil {
+			return nil, &types.ContainerNotFoundError{
+				Container:    s.options.ContainerName,
+				ResourceType: string(types.ResourceTypeDeployment),
+				ResourceName: s.options.ResourceName,
+			}
 		}
 	} else {
 		// Update first container only (default behavior)
 		if len(deployment.Spec.Template.Spec.Containers) == 0 {
-			return fmt.Errorf("no containers found in deployment %s", s.options.ResourceName)
+			return nil, &types.ContainerNotFoundError{
+				ResourceType: string(types.ResourceTypeDeployment),
+				ResourceName: s.options.ResourceName,
+			}
 		}
 
-		container := &deployment.Spec.Template.Spec.Containers[0]
-		newImage := s.getNewImageForContainer(container.Image)
-		fmt.Printf("Updating container %s image from %s to %s\n", container.Name, container.Image, newImage)
-		container.Image = newImage
-		updated = true
+		container = &deployment.Spec.Template.Spec.Containers[0]
 	}
 
+	oldImage := container.Image
+	newImage, err := s.resolveNewImage(ctx, oldImage, &deployment.Spec.Template.Spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPolicy(ctx, policy.Target{
+		Namespace: s.options.Namespace,
+		Container: container.Name,
+		OldImage:  oldImage,
+		NewImage:  newImage,
+		Workload:  deploymentWorkload(deployment),
+	}); err != nil {
+		return nil, err
+	}
+
+	// Refuse to roll out images that do not exist in the registry
+	var verification *Verification
+	if s.options.VerifyImage {
+		verification, err = s.verifyImage(ctx, newImage, &deployment.Spec.Template.Spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	change := Change{Container: container.Name, OldImage: oldImage, NewImage: newImage, Verification: verification}
+	if s.options.DryRun {
+		return &Result{
+			Namespace:    s.options.Namespace,
+			ResourceType: types.ResourceTypeDeployment,
+			ResourceName: s.options.ResourceName,
+			Container:    container.Name,
+			OldImage:     oldImage,
+			NewImage:     newImage,
+			Verification: verification,
+			Changes:      []Change{change},
+			DryRun:       true,
+		}, nil
+	}
+
+	if err := s.confirm([]Change{change}); err != nil {
+		return nil, err
+	}
+
+	container.Image = newImage
+
 	// Update the deployment
 	_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to update deployment %s: %v", s.options.ResourceName, err)
+		return nil, fmt.Errorf("failed to update deployment %s: %w", s.options.ResourceName, err)
+	}
+
+	s.progress.Emit(rollout.Event{
+		Event:      rollout.EventImageUpdated,
+		Namespace:  s.options.Namespace,
+		Deployment: s.options.ResourceName,
+		Container:  container.Name,
+		OldImage:   oldImage,
+		NewImage:   newImage,
+	},
+		fmt.Sprintf("Updating container %s image from %s to %s", container.Name, oldImage, newImage),
+		fmt.Sprintf("deployment.apps/%s image updated", s.options.ResourceName),
+	)
+
+	return &Result{
+		Namespace:    s.options.Namespace,
+		ResourceType: types.ResourceTypeDeployment,
+		ResourceName: s.options.ResourceName,
+		Container:    container.Name,
+		OldImage:     oldImage,
+		NewImage:     newImage,
+		Verification: verification,
+		Changes:      []Change{change},
+	}, nil
+}
+
+// wrapGetError turns API errors into typed errors where possible
+func (s *ImageSetter) wrapGetError(err error) error {
+	if apierrors.IsNotFound(err) {
+		return &types.NotFoundError{
+			ResourceType: string(types.ResourceTypeDeployment),
+			Name:         s.options.ResourceName,
+			Namespace:    s.options.Namespace,
+			Err:          err,
+		}
+	}
+	return fmt.Errorf("failed to get deployment %s: %w", s.options.ResourceName, err)
+}
+
+// resolveNewImage returns the image to set for a container currently running oldImage,
+// resolving --latest and bump levels to a tag first
+func (s *ImageSetter) resolveNewImage(ctx context.Context, oldImage string, podSpec *corev1.PodSpec) (string, error) {
+	tag := s.options.Tag
+	var err error
+	if s.options.Latest {
+		base := oldImage
+		if s.options.Image != "" {
+			base = s.options.Image
+		}
+		tag, err = s.resolveLatestTag(ctx, base, podSpec)
+		if err != nil {
+			return "", err
+		}
+	}
+	if s.options.BumpLevel != "" {
+		tag, err = s.bumpTag(oldImage)
+		if err != nil {
+			return "", err
+		}
 	}
+	return s.getNewImageForContainer(oldImage, tag)
+}
+
+// checkPolicy refuses new images that break an image policy of the configuration file
+func (s *ImageSetter) checkPolicy(ctx context.Context, target policy.Target) error {
+	return validator.New(s.options).ValidatePolicy(ctx, s.cfg.Policies, target)
+}
 
-	fmt.Printf("deployment.apps/%s image updated\n", s.options.ResourceName)
-	return nil
+// deploymentWorkload returns the metadata of a deployment that policy rules see
+func deploymentWorkload(deployment *appsv1.Deployment) policy.Workload {
+	return policy.Workload{
+		Kind:        "Deployment",
+		Name:        deployment.Name,
+		Namespace:   deployment.Namespace,
+		Labels:      deployment.Labels,
+		Annotations: deployment.Annotations,
+	}
 }
 
-// getNewImageForContainer returns the new image name based on options
-func (s *ImageSetter) getNewImageForContainer(currentImage string) string {
-	if s.options.Tag != "" {
+// getNewImageForContainer returns the new image name based on options and the tag to set,
+// which is either --tag or the tag resolved by --latest
+func (s *ImageSetter) getNewImageForContainer(currentImage, tag string) (string, error) {
+	if tag != "" {
 		// Check if the tag actually contains a full image name (common user mistake)
-		if strings.Contains(s.options.Tag, "/") || strings.Contains(s.options.Tag, ":") {
-			fmt.Printf("tag should only contain the version/tag part (e.g., 'v1.0.1', '7eeb161'), not a full image name. Use the image argument instead for full image names\n")
-			os.Exit(1)
+		if strings.Contains(tag, "/") || strings.Contains(tag, ":") {
+			return "", &types.InvalidReferenceError{
+				Reference: tag,
+				Reason:    "tag should only contain the version/tag part (e.g., 'v1.0.1', '7eeb161'), not a full image name. Use the image argument instead for full image names",
+			}
 		}
 
 		// If using --tag flag, extract base image name
 		var baseName string
 		if s.options.Image != "" {
 			// If image name is provided, use it as base
-			baseName = s.options.Image
-			if strings.Contains(baseName, ":") {
-				// Remove existing tag
-				parts := strings.Split(baseName, ":")
-				baseName = parts[0]
-			}
+			baseName = stripTag(s.options.Image)
 		} else {
 			// Extract base image from current container image
-			if strings.Contains(currentImage, ":") {
-				parts := strings.Split(currentImage, ":")
-				baseName = parts[0]
-			} else {
-				baseName = currentImage
-			}
+			baseName = stripTag(currentImage)
 		}
-		return baseName + ":" + s.options.Tag
+		return baseName + ":" + tag, nil
 	}
 
 	// Direct image specification
 	if s.options.Image != "" {
-		return s.options.Image
+		return s.options.Image, nil
 	}
 
-	return currentImage
+	return currentImage, nil
 }
 
-// waitForDeploymentRollout waits for the deployment rollout to complete
-func (s *ImageSetter) waitForDeploymentRollout() error {
-	ctx := context.TODO()
-	deploymentsClient := s.options.Clientset.AppsV1().Deployments(s.options.Namespace)
-
-	fmt.Printf("Waiting for deployment %s rollout to complete...\n", s.options.ResourceName)
-
-	// Create a ticker for status updates
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	// Create a timeout context
-	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
-	defer cancel()
-
-	startTime := time.Now()
-	var deploymentReadyTime time.Time
-
-	for {
-		select {
-		case <-timeoutCtx.Done():
-			return fmt.Errorf("timeout waiting for deployment %s rollout to complete", s.options.ResourceName)
-		case <-ticker.C:
-			// Get the deployment
-			deployment, err := deploymentsClient.Get(ctx, s.options.ResourceName, metav1.GetOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to get deployment %s: %v", s.options.ResourceName, err)
-			}
-
-			// Check if deployment is ready using the standard Kubernetes deployment conditions
-			deploymentReady := false
-			for _, condition := range deployment.Status.Conditions {
-				if condition.Type == "Progressing" && condition.Status == "True" && condition.Reason == "NewReplicaSetAvailable" {
-					deploymentReady = true
-					break
-				}
-			}
-
-			// Also check numeric status fields
-			if !deploymentReady {
-				deploymentReady = deployment.Status.UpdatedReplicas == deployment.Status.Replicas &&
-					deployment.Status.ReadyReplicas == deployment.Status.Replicas &&
-					deployment.Status.AvailableReplicas == deployment.Status.Replicas &&
-					deployment.Status.ObservedGeneration >= deployment.Generation
-			}
-
-			// Get pods for status information
-			podList, err := s.options.Clientset.CoreV1().Pods(s.options.Namespace).List(ctx, metav1.ListOptions{
-				LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
-			})
-			if err != nil {
-				return fmt.Errorf("failed to list pods for deployment %s: %v", s.options.ResourceName, err)
-			}
-
-			// Count pods by status
-			runningPods := 0
-			pendingPods := 0
-			terminatingPods := 0
-			otherPods := 0
-
-			for _, pod := range podList.Items {
-				if pod.DeletionTimestamp != nil {
-					terminatingPods++
-				} else {
-					switch pod.Status.Phase {
-					case "Running":
-						// Check if all containers are ready
-						allContainersReady := true
-						for _, containerStatus := range pod.Status.ContainerStatuses {
-							if !containerStatus.Ready {
-								allContainersReady = false
-								break
-							}
-						}
-						if allContainersReady {
-							runningPods++
-						} else {
-							pendingPods++
-						}
-					case "Pending":
-						pendingPods++
-					default:
-						otherPods++
-					}
-				}
-			}
-
-			// Check if rollout is complete
-			rolloutComplete := deploymentReady && runningPods == int(deployment.Status.Replicas) && terminatingPods == 0
-
-			// If deployment is ready but there are still terminating pods, check if we should wait longer
-			if deploymentReady && runningPods == int(deployment.Status.Replicas) && terminatingPods > 0 {
-				// Record when deployment became ready
-				if deploymentReadyTime.IsZero() {
-					deploymentReadyTime = time.Now()
-					duration := deploymentReadyTime.Sub(startTime).Round(time.Millisecond)
-					fmt.Printf(" ✅  New pods are ready (took %v), waiting for old pods cleanup...\n", duration)
-				}
-
-				// If we've been waiting for cleanup for more than 60 seconds, consider it done
-				if time.Since(deploymentReadyTime) > 60*time.Second {
-					totalDuration := time.Since(startTime).Round(time.Millisecond)
-					cleanupDuration := time.Since(deploymentReadyTime).Round(time.Millisecond)
-					fmt.Printf(" ⚠️  Old pods cleanup taking longer than expected, but deployment is ready\n")
-					fmt.Printf(" ✅  Deployment %s successfully rolled out (took %v total, cleanup %v ongoing)\n",
-						s.options.ResourceName, totalDuration, cleanupDuration)
-					return nil
-				}
-			}
-
-			// If rollout is complete, exit
-			if rolloutComplete {
-				totalDuration := time.Since(startTime).Round(time.Millisecond)
-				if deploymentReadyTime.IsZero() {
-					fmt.Printf(" ✅  Deployment %s successfully rolled out (took %v)\n", s.options.ResourceName, totalDuration)
-				} else {
-					cleanupDuration := time.Since(deploymentReadyTime).Round(time.Millisecond)
-					fmt.Printf(" ✅  Deployment %s successfully rolled out (took %v total, cleanup %v)\n",
-						s.options.ResourceName, totalDuration, cleanupDuration)
-				}
-				return nil
-			}
-
-			// Print progress
-			fmt.Printf(" ⏳  Waiting for rollout to finish: %d/%d pods ready, %d pending, %d terminating\n",
-				runningPods, deployment.Status.Replicas, pendingPods, terminatingPods)
-
-			// Print details for problematic pods
-			for _, pod := range podList.Items {
-				if pod.Status.Phase != "Running" || pod.DeletionTimestamp != nil {
-					status := string(pod.Status.Phase)
-					if pod.DeletionTimestamp != nil {
-						status = "Terminating"
-					}
-
-					fmt.Printf(" 🔍  Pod %s status: %s\n", pod.Name, status)
-
-					// Print container statuses for more details
-					for _, containerStatus := range pod.Status.ContainerStatuses {
-						if !containerStatus.Ready {
-							if containerStatus.State.Waiting != nil {
-								fmt.Printf("     Container %s is waiting: %s - %s\n",
-									containerStatus.Name,
-									containerStatus.State.Waiting.Reason,
-									containerStatus.State.Waiting.Message)
-							} else if containerStatus.State.Terminated != nil {
-								fmt.Printf("     Container %s terminated: %s - %s\n",
-									containerStatus.Name,
-									containerStatus.State.Terminated.Reason,
-									containerStatus.State.Terminated.Message)
-							}
-						}
-					}
-				}
-			}
-		}
+// stripTag removes the tag and digest from an image, keeping a registry port intact
+func stripTag(image string) string {
+	if at := strings.Index(image, "@"); at != -1 {
+		image = image[:at]
+	}
+	if colon := strings.LastIndex(image, ":"); colon != -1 && !strings.Contains(image[colon+1:], "/") {
+		image = image[:colon]
 	}
+	return image
 }
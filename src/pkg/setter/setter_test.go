@@ -0,0 +1,48 @@
+package setter
+
+import (
+	"testing"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+func TestGetNewImageForContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		image   string
+		tag     string
+		want    string
+	}{
+		{name: "tag of the current image", current: "nginx:1.0", tag: "1.1", want: "nginx:1.1"},
+		{name: "current image without tag", current: "nginx", tag: "1.1", want: "nginx:1.1"},
+		{name: "registry port", current: "registry.corp:5000/team/app:1.0", tag: "1.1", want: "registry.corp:5000/team/app:1.1"},
+		{name: "registry port without tag", current: "localhost:5000/app", tag: "1.1", want: "localhost:5000/app:1.1"},
+		{name: "digest", current: "nginx:1.0@sha256:abc", tag: "1.1", want: "nginx:1.1"},
+		{name: "tag of the given image", current: "nginx:1.0", image: "registry.corp:5000/nginx:2.0", tag: "2.1", want: "registry.corp:5000/nginx:2.1"},
+		{name: "given image", current: "nginx:1.0", image: "nginx:2.0", want: "nginx:2.0"},
+		{name: "nothing to change", current: "nginx:1.0", want: "nginx:1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(&types.Options{Image: tt.image}, nil)
+			got, err := s.getNewImageForContainer(tt.current, tt.tag)
+			if err != nil {
+				t.Fatalf("getNewImageForContainer: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("new image = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetNewImageForContainerRejectsImagesAsTags(t *testing.T) {
+	s := New(&types.Options{}, nil)
+	for _, tag := range []string{"nginx:1.1", "team/app"} {
+		if _, err := s.getNewImageForContainer("nginx:1.0", tag); err == nil {
+			t.Errorf("tag %q was accepted, want an error", tag)
+		}
+	}
+}
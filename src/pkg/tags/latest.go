@@ -0,0 +1,158 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+)
+
+// Selector picks the highest tag satisfying a version constraint
+type Selector struct {
+	constraint *semver.Constraint
+	// pattern extracts the version from tags using a custom scheme, nil for plain semantic versions
+	pattern           *regexp.Regexp
+	includePreRelease bool
+	// suffix is the variant suffix tags must have, e.g. "alpine", set by MatchVariant
+	suffix string
+	// singleNumber allows versions without a minor such as "1045", which are usually build numbers
+	singleNumber bool
+}
+
+// NewSelector creates a Selector. The constraint may be empty to select the highest version.
+// With a pattern only matching tags are considered and the version is read from its first capture group,
+// so schemes like "2024.05.01-abc123" can be ordered with "^(\d+\.\d+\.\d+)-[0-9a-f]+$".
+func NewSelector(constraint, pattern string, includePreRelease bool) (*Selector, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Selector{constraint: c, includePreRelease: includePreRelease}
+	if pattern != "" {
+		s.pattern, err = CompileTagPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// CompileTagPattern compiles a tag pattern and checks it has a capture group for the version
+func CompileTagPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("tag pattern %q needs a capture group for the version", pattern)
+	}
+	return re, nil
+}
+
+// MatchVariant restricts the selection to tags of the same variant as the current tag, so that
+// "1.25.3-alpine" is only replaced by another "-alpine" tag and a build number like "1045" by another one.
+// Tag patterns define their own scheme and are not restricted.
+func (s *Selector) MatchVariant(current string) {
+	if s.pattern != nil {
+		return
+	}
+	version, err := semver.Parse(current)
+	if err != nil {
+		return
+	}
+	s.suffix = version.Suffix()
+	s.singleNumber = version.Components() == 1
+}
+
+// Version returns the version of a tag, nil when the tag is not a version in the selector's scheme.
+// Without a tag pattern versions need at least a major and a minor, unless MatchVariant allowed
+// single numbers, so that dates and build numbers are not ranked above real versions.
+func (s *Selector) Version(tag string) *semver.Version {
+	value := tag
+	if s.pattern != nil {
+		match := s.pattern.FindStringSubmatch(tag)
+		if match == nil {
+			return nil
+		}
+		value = trimLeadingZeros(match[1])
+	}
+
+	version, err := semver.Parse(value)
+	if err != nil {
+		return nil
+	}
+	if version.IsPreRelease() && !s.includePreRelease {
+		return nil
+	}
+	if s.pattern == nil {
+		if version.Suffix() != s.suffix {
+			return nil
+		}
+		if version.Components() < 2 && !s.singleNumber {
+			return nil
+		}
+	}
+	return version
+}
+
+// Select returns the highest tag satisfying the constraint.
+// Tags with the same version are ordered by name, so the result is stable.
+func (s *Selector) Select(names []string) (string, error) {
+	var best string
+	var bestVersion *semver.Version
+
+	for _, name := range names {
+		version := s.Version(name)
+		if version == nil || !s.constraint.Check(version) {
+			continue
+		}
+		if bestVersion == nil || version.Compare(bestVersion) > 0 || (version.Compare(bestVersion) == 0 && name > best) {
+			best, bestVersion = name, version
+		}
+	}
+
+	if bestVersion == nil {
+		return "", fmt.Errorf("no tag matches %s", s.describe())
+	}
+	return best, nil
+}
+
+// describe explains what the selector looks for, for error messages
+func (s *Selector) describe() string {
+	var parts []string
+	if c := s.constraint.String(); c != "" && c != "*" {
+		parts = append(parts, fmt.Sprintf("version constraint %q", c))
+	} else {
+		parts = append(parts, "a semantic version")
+	}
+	if s.pattern != nil {
+		parts = append(parts, fmt.Sprintf("tag pattern %q", s.pattern.String()))
+	}
+	if s.suffix != "" {
+		parts = append(parts, fmt.Sprintf("variant %q", s.suffix))
+	}
+	if !s.includePreRelease {
+		parts = append(parts, "excluding pre-releases")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// trimLeadingZeros turns date-like versions such as "2024.05.01" into "2024.5.1"
+func trimLeadingZeros(version string) string {
+	core, suffix := version, ""
+	if i := strings.IndexAny(version, "-+"); i != -1 {
+		core, suffix = version[:i], version[i:]
+	}
+
+	parts := strings.Split(core, ".")
+	for i, part := range parts {
+		trimmed := strings.TrimLeft(part, "0")
+		if trimmed == "" && part != "" {
+			trimmed = "0"
+		}
+		parts[i] = trimmed
+	}
+	return strings.Join(parts, ".") + suffix
+}
@@ -0,0 +1,52 @@
+package tags
+
+import "testing"
+
+func TestSelectorSelect(t *testing.T) {
+	names := []string{"1.24.0", "1.25.3", "1.26.0", "1.25.2-alpine", "1.25.4-alpine", "1.26.1-alpine", "1.25.5-rc.1-alpine", "1.25.9-slim", "1.27.0-alpine", "1045"}
+
+	tests := []struct {
+		name              string
+		constraint        string
+		current           string
+		includePreRelease bool
+		want              string
+	}{
+		{name: "highest release", constraint: "*", current: "1.24.0", want: "1.26.0"},
+		{name: "constraint", constraint: "~1.25", current: "1.24.0", want: "1.25.3"},
+		{name: "variant", constraint: "*", current: "1.24.0-alpine", want: "1.27.0-alpine"},
+		{name: "variant of the lower bound", constraint: "~1.27", current: "1.24.0-alpine", want: "1.27.0-alpine"},
+		{name: "variant with tilde", constraint: "~1.25", current: "1.24.0-alpine", want: "1.25.4-alpine"},
+		{name: "variant with caret", constraint: "^1.25", current: "1.25.0-slim", want: "1.25.9-slim"},
+		{name: "variant pre-release", constraint: "~1.25", current: "1.24.0-alpine", includePreRelease: true, want: "1.25.5-rc.1-alpine"},
+		{name: "build number", constraint: "*", current: "1040", want: "1045"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := NewSelector(tt.constraint, "", tt.includePreRelease)
+			if err != nil {
+				t.Fatalf("NewSelector: %v", err)
+			}
+			selector.MatchVariant(tt.current)
+			got, err := selector.Select(names)
+			if err != nil {
+				t.Fatalf("Select: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Select = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorSelectWithoutMatch(t *testing.T) {
+	selector, err := NewSelector("~1.27", "", false)
+	if err != nil {
+		t.Fatalf("NewSelector: %v", err)
+	}
+	selector.MatchVariant("1.25.0-alpine")
+	if got, err := selector.Select([]string{"1.27.0", "1.26.1-alpine"}); err == nil {
+		t.Errorf("Select = %s, want an error", got)
+	}
+}
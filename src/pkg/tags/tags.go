@@ -0,0 +1,215 @@
+package tags
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/getter"
+	"github.com/reedchan7/kubectl-image/src/pkg/reference"
+	"github.com/reedchan7/kubectl-image/src/pkg/registry"
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+)
+
+// maxConcurrentLookups limits parallel registry requests when sorting by time
+const maxConcurrentLookups = 8
+
+// TagLister lists the registry tags of the image a resource runs
+type TagLister struct {
+	options  *types.Options
+	registry *registry.Client
+}
+
+// Result holds the tags of a repository
+type Result struct {
+	// Repository is the fully qualified repository name
+	Repository string
+	// CurrentImage is the image the resource currently runs
+	CurrentImage string
+	Tags         []Tag
+}
+
+// Tag is a single registry tag
+type Tag struct {
+	Name string
+	// Version is nil for tags that are not semantic versions
+	Version *semver.Version
+	// Created is zero unless sorting by time, or when the registry does not provide it
+	Created time.Time
+}
+
+// New creates a new TagLister
+func New(options *types.Options) *TagLister {
+	return &TagLister{
+		options:  options,
+		registry: registry.New(options),
+	}
+}
+
+// List returns the filtered and sorted tags of the repository the resource's image comes from
+func (l *TagLister) List(ctx context.Context) (*Result, error) {
+	image, err := getter.New(l.options).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.Parse(image.Image)
+	if err != nil {
+		return nil, &types.InvalidReferenceError{Reference: image.Image, Reason: err.Error()}
+	}
+
+	// Private registries are accessed with the same pull secrets as the kubelet uses
+	l.registry.UsePullSecrets(image.ServiceAccountName, image.ImagePullSecrets)
+
+	names, err := l.registry.ListTags(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", ref.Name(), err)
+	}
+
+	names, err = Filter(names, l.options.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Repository:   ref.Name(),
+		CurrentImage: image.Image,
+		Tags:         make([]Tag, 0, len(names)),
+	}
+	for _, name := range names {
+		tag := Tag{Name: name}
+		if version, err := semver.Parse(name); err == nil {
+			tag.Version = version
+		}
+		result.Tags = append(result.Tags, tag)
+	}
+
+	sortBy, exists := types.ValidTagSorts[strings.ToLower(l.options.TagSort)]
+	if !exists {
+		sortBy = types.TagSortSemver
+	}
+
+	switch sortBy {
+	case types.TagSortLexical:
+		SortLexical(result.Tags)
+	case types.TagSortTime:
+		if err := l.lookupCreated(ctx, ref, result.Tags); err != nil {
+			return nil, err
+		}
+		SortTime(result.Tags)
+	default:
+		SortSemver(result.Tags)
+	}
+
+	return result, nil
+}
+
+// lookupCreated fills in the creation time of every tag, with bounded concurrency.
+// Tags whose lookup fails keep a zero time and are sorted last, only a cancelled
+// context aborts the listing.
+func (l *TagLister) lookupCreated(ctx context.Context, ref *reference.Reference, tags []Tag) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	var firstErr error
+	sem := make(chan struct{}, maxConcurrentLookups)
+
+	for i := range tags {
+		wg.Add(1)
+		go func(tag *Tag) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			created, err := l.registry.Created(ctx, ref.WithTag(tag.Name))
+			if err != nil {
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", tag.Name, err)
+				}
+				mu.Unlock()
+				return
+			}
+			tag.Created = created
+		}(&tags[i])
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 && l.options.ErrOut != nil {
+		fmt.Fprintf(l.options.ErrOut, "Warning: failed to get the creation time of %d tag(s), they are listed last: %v\n", failed, firstErr)
+	}
+	return nil
+}
+
+// Filter returns the tags matching the regular expression, all tags when it is empty
+func Filter(names []string, pattern string) ([]string, error) {
+	if pattern == "" {
+		return names, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag filter %q: %w", pattern, err)
+	}
+
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		if re.MatchString(name) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, nil
+}
+
+// SortSemver sorts semantic versions newest first, followed by the other tags alphabetically
+func SortSemver(tags []Tag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := tags[i].Version, tags[j].Version
+		switch {
+		case a != nil && b != nil:
+			if c := a.Compare(b); c != 0 {
+				return c > 0
+			}
+			return tags[i].Name < tags[j].Name
+		case a != nil:
+			return true
+		case b != nil:
+			return false
+		default:
+			return tags[i].Name < tags[j].Name
+		}
+	})
+}
+
+// SortLexical sorts tags alphabetically
+func SortLexical(tags []Tag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+// SortTime sorts tags by creation time newest first, tags without time come last
+func SortTime(tags []Tag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := tags[i].Created, tags[j].Created
+		switch {
+		case a.IsZero() && b.IsZero():
+			return tags[i].Name < tags[j].Name
+		case a.IsZero():
+			return false
+		case b.IsZero():
+			return true
+		default:
+			return a.After(b)
+		}
+	})
+}
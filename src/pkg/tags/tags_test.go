@@ -0,0 +1,124 @@
+package tags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestOptions returns options for a deployment running team/app:1.0.0 from a registry
+// stand-in, which serves the tags with the given creation times. Tags without a time fail.
+func newTestOptions(t *testing.T, created map[string]time.Time, names []string) *types.Options {
+	t.Helper()
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"tags": names})
+	})
+	mux.HandleFunc("/v2/team/app/manifests/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		at, exists := created[r.PathValue("tag")]
+		if !exists {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"mediaType":   "application/vnd.oci.image.manifest.v1+json",
+			"annotations": map[string]string{"org.opencontainers.image.created": at.Format(time.RFC3339)},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	image := strings.TrimPrefix(server.URL, "http://") + "/team/app:1.0.0"
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+			},
+		},
+	}
+
+	return &types.Options{
+		Clientset:    fake.NewClientset(deployment),
+		Namespace:    "default",
+		ResourceType: "deployment",
+		ResourceName: "app",
+	}
+}
+
+func tagNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func TestListSortsByVersion(t *testing.T) {
+	options := newTestOptions(t, nil, []string{"1.0.0", "latest", "1.10.0", "1.2.0", "2.0.0-rc.1"})
+
+	result, err := New(options).List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := strings.Join(tagNames(result.Tags), ","), "2.0.0-rc.1,1.10.0,1.2.0,1.0.0,latest"; got != want {
+		t.Errorf("tags = %s, want %s", got, want)
+	}
+	if !strings.HasSuffix(result.CurrentImage, "/team/app:1.0.0") {
+		t.Errorf("current image = %s", result.CurrentImage)
+	}
+}
+
+func TestListSortsByTimeDespiteFailedLookups(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	created := map[string]time.Time{
+		"1.0.0": base,
+		"1.1.0": base.Add(48 * time.Hour),
+		"1.2.0": base.Add(24 * time.Hour),
+	}
+	options := newTestOptions(t, created, []string{"1.0.0", "broken", "1.1.0", "1.2.0"})
+	options.TagSort = string(types.TagSortTime)
+	var errOut bytes.Buffer
+	options.ErrOut = &errOut
+
+	result, err := New(options).List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := strings.Join(tagNames(result.Tags), ","), "1.1.0,1.2.0,1.0.0,broken"; got != want {
+		t.Errorf("tags = %s, want %s", got, want)
+	}
+	if last := result.Tags[len(result.Tags)-1]; !last.Created.IsZero() {
+		t.Errorf("failed tag has creation time %v, want zero", last.Created)
+	}
+	if !strings.Contains(errOut.String(), "1 tag(s)") {
+		t.Errorf("warning = %q, want the failed lookup to be reported", errOut.String())
+	}
+}
+
+func TestListFiltersTags(t *testing.T) {
+	options := newTestOptions(t, nil, []string{"1.0.0", "1.0.0-alpine", "1.1.0-alpine"})
+	options.TagFilter = "-alpine$"
+
+	result, err := New(options).List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := strings.Join(tagNames(result.Tags), ","), "1.1.0-alpine,1.0.0-alpine"; got != want {
+		t.Errorf("tags = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotFoundError is returned when the target resource does not exist
+type NotFoundError struct {
+	ResourceType string
+	Name         string
+	Namespace    string
+	Err          error
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found in namespace %s", e.ResourceType, e.Name, e.Namespace)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// ContainerNotFoundError is returned when the requested container does not exist in the resource
+type ContainerNotFoundError struct {
+	Container    string
+	ResourceType string
+	ResourceName string
+}
+
+func (e *ContainerNotFoundError) Error() string {
+	if e.Container == "" {
+		return fmt.Sprintf("no containers found in %s %s", e.ResourceType, e.ResourceName)
+	}
+	return fmt.Sprintf("container %s not found in %s %s", e.Container, e.ResourceType, e.ResourceName)
+}
+
+// InvalidReferenceError is returned when an image reference or tag is malformed
+type InvalidReferenceError struct {
+	Reference string
+	Reason    string
+}
+
+func (e *InvalidReferenceError) Error() string {
+	if e.Reference == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("invalid image reference %q: %s", e.Reference, e.Reason)
+}
+
+// ImageNotFoundError is returned when an image does not exist in its registry
+type ImageNotFoundError struct {
+	Image string
+	Err   error
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("image %s not found in registry", e.Image)
+}
+
+func (e *ImageNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// RolloutFailedError is returned when a rollout does not complete
+type RolloutFailedError struct {
+	ResourceName string
+	Reason       string
+	// Warnings holds the last warning events seen during the rollout
+	Warnings []string
+	Err      error
+}
+
+func (e *RolloutFailedError) Error() string {
+	if e.Reason == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Reason
+}
+
+func (e *RolloutFailedError) Unwrap() error {
+	return e.Err
+}
+
+// ExitError is returned by commands that report a condition through the process exit code,
+// e.g. outdated images found in CI
+type ExitError struct {
+	Code   int
+	Reason string
+}
+
+func (e *ExitError) Error() string {
+	return e.Reason
+}
+
+// PolicyViolationError is returned when a new image breaks a rule of an image policy
+type PolicyViolationError struct {
+	Policy string
+	Rule   string
+	Image  string
+	Reason string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("image %s violates rule %s of policy %s: %s", e.Image, e.Rule, e.Policy, e.Reason)
+}
+
+// MissingPermissionsError is returned when the current user lacks permissions a command needs
+type MissingPermissionsError struct {
+	Namespace   string
+	Permissions []string
+	// Rules are the Role rules that grant the missing permissions of the namespace
+	Rules string
+	// ClusterRules are the ClusterRole rules that grant the missing cluster-scoped permissions
+	ClusterRules string
+}
+
+func (e *MissingPermissionsError) Error() string {
+	message := fmt.Sprintf("missing permissions in namespace %s: %s", e.Namespace, strings.Join(e.Permissions, ", "))
+	if e.Rules != "" {
+		message += fmt.Sprintf("\nAsk for a Role in namespace %s with:\n%s", e.Namespace, strings.TrimSuffix(e.Rules, "\n"))
+	}
+	if e.ClusterRules != "" {
+		message += fmt.Sprintf("\nAsk for a ClusterRole with:\n%s", strings.TrimSuffix(e.ClusterRules, "\n"))
+	}
+	return message
+}
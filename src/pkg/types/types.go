@@ -1,6 +1,12 @@
 package types
 
-import "k8s.io/client-go/kubernetes"
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
 
 // Options holds the command line options
 type Options struct {
@@ -13,7 +19,101 @@ type Options struct {
 	TagOnly       bool
 	Wait          bool
 
+	// Rollout wait tuning, only used together with Wait
+	Timeout      time.Duration
+	PollInterval time.Duration
+	CleanupGrace time.Duration
+	WaitFor      string
+
+	// Output options
+	Progress string
+	NoEmoji  bool
+
+	// Registry options
+	TagFilter string
+	TagSort   string
+	PlainHTTP bool
+	// VerifyImage resolves the new image in its registry before updating
+	VerifyImage bool
+
+	// Latest sets the highest registry tag matching LatestConstraint instead of a given tag
+	Latest           bool
+	LatestConstraint string
+	// TagPattern is a regular expression whose first capture group holds the version of a tag
+	TagPattern        string
+	IncludePreRelease bool
+
+	// BumpLevel sets the next major, minor or patch version of the current tag
+	BumpLevel string
+	// BumpPreRelease makes the bumped version a pre-release with this name, e.g. "rc"
+	BumpPreRelease string
+
+	// ContainerImages sets the images of several containers in a single update, by container name
+	ContainerImages map[string]string
+
+	// Source and target of a promotion, empty contexts use the current context
+	FromContext   string
+	ToContext     string
+	FromNamespace string
+	ToNamespace   string
+	// PinDigest promotes the digests the source runs instead of its tags
+	PinDigest bool
+
+	// Left and Right are the CONTEXT/NAMESPACE sides compared by diff
+	Left  string
+	Right string
+
+	// DryRun computes the new image without updating the resource
+	DryRun bool
+	// Confirm asks before every update, Yes skips the confirmation of protected contexts and namespaces
+	Confirm bool
+	Yes     bool
+
+	// Filename is the file read by commands working on files, "-" for stdin
+	Filename string
+	// Filenames are local manifest files or directories edited instead of the cluster
+	Filenames []string
+	Recursive bool
+	// Kustomize is a kustomization directory whose images entry for ImageName is edited instead of the cluster
+	Kustomize string
+	ImageName string
+	// HelmValues is a Helm values file whose image at the dotted ValuesPath is edited instead of the cluster
+	HelmValues string
+	ValuesPath string
+
+	// Scan options for commands covering many workloads
+	AllNamespaces bool
+	Selector      string
+	Output        string
+	ShowAll       bool
+	FailOn        string
+	// Concurrency and RegistryQPS limit the parallel registry lookups
+	Concurrency int
+	RegistryQPS float64
+
+	// StateFile records the progress of a rollout plan, Restart ignores the recorded progress
+	StateFile string
+	Restart   bool
+
+	// ConfigFile replaces the configuration file, for commands that only read it
+	ConfigFile string
+
+	// Contexts and AllContexts run a command in several kubeconfig contexts at once, AllContexts
+	// is a glob of context names. Sequential handles one context after the other instead.
+	Contexts    []string
+	AllContexts string
+	Sequential  bool
+
+	// Context is the kubeconfig context of the cluster, used to decide which policies apply
+	Context   string
 	Clientset kubernetes.Interface
+
+	// Out receives progress output, nothing is printed when it is nil
+	Out io.Writer
+	// In answers confirmation prompts, which are written to ErrOut. It is created once per command
+	// so that piped answers buffered for one prompt are still there for the next.
+	In     *bufio.Reader
+	ErrOut io.Writer
 }
 
 // ResourceType represents supported Kubernetes resource types
@@ -33,3 +133,111 @@ var ValidResourceTypes = map[string]ResourceType{
 	"pods":        ResourceTypePod,
 	"po":          ResourceTypePod,
 }
+
+// ResourceKinds maps the resource types to the kind of their manifests
+var ResourceKinds = map[ResourceType]string{
+	ResourceTypeDeployment: "Deployment",
+	ResourceTypePod:        "Pod",
+}
+
+// WaitCondition represents the point at which a rollout wait is considered done
+type WaitCondition string
+
+const (
+	// WaitForReady returns as soon as all new pods are ready
+	WaitForReady WaitCondition = "ready"
+	// WaitForAvailable returns once the deployment reports the new pods as available
+	WaitForAvailable WaitCondition = "available"
+	// WaitForCleanup additionally waits for the old pods to terminate
+	WaitForCleanup WaitCondition = "cleanup"
+)
+
+// ValidWaitConditions returns a list of supported wait conditions
+var ValidWaitConditions = map[string]WaitCondition{
+	"ready":     WaitForReady,
+	"available": WaitForAvailable,
+	"cleanup":   WaitForCleanup,
+}
+
+// ProgressFormat represents the output format of rollout progress
+type ProgressFormat string
+
+const (
+	// ProgressHuman prints human readable progress lines
+	ProgressHuman ProgressFormat = "human"
+	// ProgressJSON prints newline-delimited JSON events
+	ProgressJSON ProgressFormat = "json"
+)
+
+// ValidProgressFormats returns a list of supported progress formats
+var ValidProgressFormats = map[string]ProgressFormat{
+	"human": ProgressHuman,
+	"json":  ProgressJSON,
+}
+
+// TagSort represents the order of registry tags
+type TagSort string
+
+const (
+	// TagSortSemver sorts semantic versions newest first, other tags follow
+	TagSortSemver TagSort = "semver"
+	// TagSortLexical sorts tags alphabetically
+	TagSortLexical TagSort = "lexical"
+	// TagSortTime sorts tags by image creation time, newest first
+	TagSortTime TagSort = "time"
+)
+
+// ValidTagSorts returns a list of supported tag sort orders
+var ValidTagSorts = map[string]TagSort{
+	"semver":  TagSortSemver,
+	"lexical": TagSortLexical,
+	"time":    TagSortTime,
+}
+
+// BumpLevel represents the version component incremented by bump
+type BumpLevel string
+
+const (
+	BumpMajor BumpLevel = "major"
+	BumpMinor BumpLevel = "minor"
+	BumpPatch BumpLevel = "patch"
+)
+
+// ValidBumpLevels returns a list of supported bump levels
+var ValidBumpLevels = map[string]BumpLevel{
+	"major": BumpMajor,
+	"minor": BumpMinor,
+	"patch": BumpPatch,
+}
+
+// OutputFormat represents the output format of reports
+type OutputFormat string
+
+const (
+	// OutputTable prints an aligned table
+	OutputTable OutputFormat = "table"
+	// OutputJSON prints a JSON document
+	OutputJSON OutputFormat = "json"
+)
+
+// ValidOutputFormats returns a list of supported output formats
+var ValidOutputFormats = map[string]OutputFormat{
+	"table": OutputTable,
+	"json":  OutputJSON,
+}
+
+// UpdateLevel represents how far a newer version is from the current one
+type UpdateLevel string
+
+const (
+	UpdatePatch UpdateLevel = "patch"
+	UpdateMinor UpdateLevel = "minor"
+	UpdateMajor UpdateLevel = "major"
+)
+
+// ValidUpdateLevels returns a list of supported update levels
+var ValidUpdateLevels = map[string]UpdateLevel{
+	"patch": UpdatePatch,
+	"minor": UpdateMinor,
+	"major": UpdateMajor,
+}
@@ -1,12 +1,23 @@
 package validator
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
+	"github.com/reedchan7/kubectl-image/src/pkg/policy"
+	"github.com/reedchan7/kubectl-image/src/pkg/semver"
+	"github.com/reedchan7/kubectl-image/src/pkg/tags"
 	"github.com/reedchan7/kubectl-image/src/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// preReleaseNamePattern matches pre-release names such as "rc" or "beta"
+var preReleaseNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
 // Validator handles input validation
 type Validator struct {
 	options *types.Options
@@ -21,6 +32,67 @@ func New(options *types.Options) *Validator {
 
 // ValidateSet validates the input options for set command
 func (v *Validator) ValidateSet() error {
+	if err := v.validateTarget(); err != nil {
+		return err
+	}
+
+	if err := v.validateImageOptions(); err != nil {
+		return err
+	}
+
+	if err := v.validateWaitOptions(); err != nil {
+		return err
+	}
+
+	if v.options.DryRun && v.options.Wait {
+		return fmt.Errorf("--dry-run and --wait cannot be used together")
+	}
+
+	if err := v.validateFilenames(); err != nil {
+		return err
+	}
+
+	if err := v.validateConfirmOptions(); err != nil {
+		return err
+	}
+
+	if err := v.validateContexts(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidatePolicy evaluates the image policies of the configuration file against a computed new image
+// and returns a *types.PolicyViolationError naming the failing policy and rule.
+// The labels of the target namespace are looked up in the cluster when a rule may need them.
+func (v *Validator) ValidatePolicy(ctx context.Context, policies []policy.Policy, target policy.Target) error {
+	if target.Context == "" {
+		target.Context = v.options.Context
+	}
+
+	if target.NamespaceLabels == nil && target.Namespace != "" && v.options.Clientset != nil &&
+		policy.NeedsNamespaceLabels(policies, target) {
+		namespace, err := v.options.Clientset.CoreV1().Namespaces().Get(ctx, target.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get labels of namespace %s for policy rules: %w", target.Namespace, err)
+		}
+		target.NamespaceLabels = namespace.Labels
+	}
+	err := policy.Evaluate(policies, target)
+	var violation *types.PolicyViolationError
+	if target.Namespace == "" && errors.As(err, &violation) {
+		for i := range policies {
+			if policies[i].Name == violation.Policy && len(policies[i].Namespaces) > 0 {
+				return fmt.Errorf("%w (the namespace is unknown, so the policy applies, pass -n to name it)", err)
+			}
+		}
+	}
+	return err
+}
+
+// ValidateBump validates the input options for bump command
+func (v *Validator) ValidateBump() error {
 	if err := v.validateResourceType(); err != nil {
 		return err
 	}
@@ -29,15 +101,154 @@ func (v *Validator) ValidateSet() error {
 		return err
 	}
 
-	if err := v.validateImageOptions(); err != nil {
+	if _, exists := types.ValidBumpLevels[strings.ToLower(v.options.BumpLevel)]; !exists {
+		return fmt.Errorf("unsupported bump level: %s (must be one of major, minor, patch)", v.options.BumpLevel)
+	}
+
+	if v.options.BumpPreRelease != "" && !preReleaseNamePattern.MatchString(v.options.BumpPreRelease) {
+		return fmt.Errorf("invalid --pre value %q: must start with a letter and contain only letters and digits", v.options.BumpPreRelease)
+	}
+
+	if err := v.validateWaitOptions(); err != nil {
 		return err
 	}
 
-	return nil
+	if v.options.DryRun && v.options.Wait {
+		return fmt.Errorf("--dry-run and --wait cannot be used together")
+	}
+
+	return v.validateConfirmOptions()
+}
+
+// ValidatePromote validates the input options for promote command
+func (v *Validator) ValidatePromote() error {
+	if err := v.validateResourceType(); err != nil {
+		return err
+	}
+
+	if err := v.validateResourceName(); err != nil {
+		return err
+	}
+
+	if types.ValidResourceTypes[strings.ToLower(v.options.ResourceType)] != types.ResourceTypeDeployment {
+		return fmt.Errorf("promote is only supported for deployments")
+	}
+
+	if v.options.FromContext == v.options.ToContext && v.options.FromNamespace == v.options.ToNamespace {
+		return fmt.Errorf("source and target are the same, use --from-context/--to-context or --from-namespace/--to-namespace")
+	}
+
+	if err := v.validateWaitOptions(); err != nil {
+		return err
+	}
+
+	if v.options.DryRun && v.options.Wait {
+		return fmt.Errorf("--dry-run and --wait cannot be used together")
+	}
+
+	return v.validateConfirmOptions()
 }
 
 // ValidateGet validates the input options for get command
 func (v *Validator) ValidateGet() error {
+	if err := v.validateTarget(); err != nil {
+		return err
+	}
+
+	if err := v.validateFilenames(); err != nil {
+		return err
+	}
+
+	return v.validateContexts()
+}
+
+// validateTarget validates the resource, the image name of a kustomization or the path in a values file
+func (v *Validator) validateTarget() error {
+	sources := 0
+	for _, set := range []bool{len(v.options.Filenames) > 0, v.options.Kustomize != "", v.options.HelmValues != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of --filename, --kustomize and --helm-values can be used")
+	}
+
+	switch {
+	case v.options.HelmValues != "":
+		return v.validateHelmValues()
+	case v.options.Kustomize != "":
+		return v.validateKustomize()
+	default:
+		if v.options.ValuesPath != "" {
+			return fmt.Errorf("--path can only be used with --helm-values")
+		}
+		if err := v.validateResourceType(); err != nil {
+			return err
+		}
+		return v.validateResourceName()
+	}
+}
+
+// validateKustomize validates the options of kustomization images entries
+func (v *Validator) validateKustomize() error {
+	if v.options.ImageName == "" {
+		return fmt.Errorf("image name is required, e.g. --kustomize overlays/prod myapp")
+	}
+	if strings.ContainsAny(v.options.ImageName, ":@") {
+		return fmt.Errorf("invalid image name %q: give the name of the images entry without tag or digest", v.options.ImageName)
+	}
+	if v.options.ContainerName != "" {
+		return fmt.Errorf("--container cannot be used with --kustomize, images entries apply to all containers using the image")
+	}
+	if v.options.Wait {
+		return fmt.Errorf("--wait cannot be used with --kustomize, kustomizations are not rolled out")
+	}
+	return nil
+}
+
+// validateHelmValues validates the options of Helm values files
+func (v *Validator) validateHelmValues() error {
+	if v.options.ValuesPath == "" {
+		return fmt.Errorf("--path is required with --helm-values, e.g. --path app.image")
+	}
+	for _, key := range strings.Split(v.options.ValuesPath, ".") {
+		if key == "" {
+			return fmt.Errorf("invalid --path %q: keys must be separated by single dots", v.options.ValuesPath)
+		}
+	}
+	if v.options.ContainerName != "" {
+		return fmt.Errorf("--container cannot be used with --helm-values, --path selects the image")
+	}
+	if v.options.Wait {
+		return fmt.Errorf("--wait cannot be used with --helm-values, values files are not rolled out")
+	}
+	return nil
+}
+
+// ValidateStatus validates the input options for status command
+func (v *Validator) ValidateStatus() error {
+	if err := v.validateResourceType(); err != nil {
+		return err
+	}
+
+	if err := v.validateResourceName(); err != nil {
+		return err
+	}
+
+	if types.ValidResourceTypes[strings.ToLower(v.options.ResourceType)] != types.ResourceTypeDeployment {
+		return fmt.Errorf("rollout status is only supported for deployments")
+	}
+
+	if err := v.validateWaitOptions(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateTags validates the input options for tags command
+func (v *Validator) ValidateTags() error {
 	if err := v.validateResourceType(); err != nil {
 		return err
 	}
@@ -46,6 +257,212 @@ func (v *Validator) ValidateGet() error {
 		return err
 	}
 
+	if v.options.TagSort != "" {
+		if _, exists := types.ValidTagSorts[strings.ToLower(v.options.TagSort)]; !exists {
+			return fmt.Errorf("unsupported --sort value: %s (must be one of semver, lexical, time)", v.options.TagSort)
+		}
+	}
+
+	if v.options.TagFilter != "" {
+		if _, err := regexp.Compile(v.options.TagFilter); err != nil {
+			return fmt.Errorf("invalid --filter regular expression: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateList validates the input options for list command
+func (v *Validator) ValidateList() error {
+	if v.options.AllNamespaces && v.options.Namespace != "" {
+		return fmt.Errorf("--namespace and --all-namespaces cannot be used together")
+	}
+
+	if err := v.validateOutput(); err != nil {
+		return err
+	}
+
+	return v.validateContexts()
+}
+
+// ValidateOutdated validates the input options for outdated command
+func (v *Validator) ValidateOutdated() error {
+	if err := v.validateOutput(); err != nil {
+		return err
+	}
+
+	if v.options.FailOn != "" {
+		if _, exists := types.ValidUpdateLevels[strings.ToLower(v.options.FailOn)]; !exists {
+			return fmt.Errorf("unsupported --fail-on value: %s (must be one of patch, minor, major)", v.options.FailOn)
+		}
+	}
+
+	if v.options.Concurrency < 0 {
+		return fmt.Errorf("--concurrency must not be negative")
+	}
+
+	if v.options.RegistryQPS < 0 {
+		return fmt.Errorf("--registry-qps must not be negative")
+	}
+
+	return nil
+}
+
+// ValidateDiff validates the input options for diff command
+func (v *Validator) ValidateDiff() error {
+	if v.options.Left == "" || v.options.Right == "" {
+		return fmt.Errorf("both --left and --right are required, e.g. --left staging/shop --right prod/shop")
+	}
+
+	if v.options.Left == v.options.Right {
+		return fmt.Errorf("--left and --right must not be the same")
+	}
+
+	return v.validateOutput()
+}
+
+// ValidateExport validates the input options for export command
+func (v *Validator) ValidateExport() error {
+	if v.options.AllNamespaces && v.options.Namespace != "" {
+		return fmt.Errorf("--namespace and --all-namespaces cannot be used together")
+	}
+
+	return nil
+}
+
+// ValidateApply validates the input options for apply and verify commands
+func (v *Validator) ValidateApply() error {
+	if v.options.Filename == "" {
+		return fmt.Errorf("a lock file is required, use -f images.lock.yaml")
+	}
+
+	if v.options.Concurrency < 0 {
+		return fmt.Errorf("--parallel must not be negative")
+	}
+
+	if err := v.validateWaitOptions(); err != nil {
+		return err
+	}
+
+	if v.options.DryRun && v.options.Wait {
+		return fmt.Errorf("--dry-run and --wait cannot be used together")
+	}
+
+	if v.options.Confirm && strings.ToLower(v.options.Output) == string(types.OutputJSON) {
+		return fmt.Errorf("--confirm cannot be used with -o json")
+	}
+
+	if err := v.validateConfirmOptions(); err != nil {
+		return err
+	}
+
+	return v.validateOutput()
+}
+
+// ValidatePolicyTest validates the input options for policy test command
+func (v *Validator) ValidatePolicyTest() error {
+	if v.options.Filename == "" {
+		return fmt.Errorf("a samples file is required, use -f samples.yaml")
+	}
+
+	return v.validateOutput()
+}
+
+// ValidateRolloutPlan validates the input options for rollout-plan command
+func (v *Validator) ValidateRolloutPlan() error {
+	if v.options.Filename == "" {
+		return fmt.Errorf("a rollout plan is required, use -f plan.yaml")
+	}
+
+	if v.options.Filename == "-" {
+		return fmt.Errorf("a rollout plan cannot be read from stdin, its progress is saved next to it")
+	}
+
+	if v.options.DryRun && v.options.Restart {
+		return fmt.Errorf("--dry-run and --restart cannot be used together")
+	}
+
+	return nil
+}
+
+// validateContexts validates the options running a command in several kubeconfig contexts
+func (v *Validator) validateContexts() error {
+	multiple := len(v.options.Contexts) > 0 || v.options.AllContexts != ""
+	if !multiple {
+		if v.options.Sequential {
+			return fmt.Errorf("--sequential can only be used with --contexts or --all-contexts")
+		}
+		return nil
+	}
+
+	if len(v.options.Contexts) > 0 && v.options.AllContexts != "" {
+		return fmt.Errorf("--contexts and --all-contexts cannot be used together")
+	}
+	if len(v.options.Filenames) > 0 || v.options.Kustomize != "" || v.options.HelmValues != "" {
+		return fmt.Errorf("--contexts and --all-contexts only apply to the cluster")
+	}
+	if v.options.Confirm && !v.options.Sequential {
+		return fmt.Errorf("--confirm needs --sequential when setting images in several contexts")
+	}
+	return nil
+}
+
+// validateConfirmOptions validates --confirm and --yes, which only apply to updates of the cluster
+func (v *Validator) validateConfirmOptions() error {
+	if !v.options.Confirm && !v.options.Yes {
+		return nil
+	}
+	if v.options.Confirm && v.options.Yes {
+		return fmt.Errorf("--confirm and --yes cannot be used together")
+	}
+	if v.options.Confirm && v.options.DryRun {
+		return fmt.Errorf("--confirm cannot be used with --dry-run, which changes nothing")
+	}
+	if len(v.options.Filenames) > 0 || v.options.Kustomize != "" || v.options.HelmValues != "" {
+		return fmt.Errorf("--confirm and --yes only apply to updates of the cluster")
+	}
+	return nil
+}
+
+// validateFilenames validates the local manifest options
+func (v *Validator) validateFilenames() error {
+	if len(v.options.Filenames) == 0 {
+		if v.options.Recursive {
+			return fmt.Errorf("--recursive can only be used with --filename")
+		}
+		return nil
+	}
+
+	stdin := 0
+	for _, filename := range v.options.Filenames {
+		if filename == "" {
+			return fmt.Errorf("--filename must not be empty")
+		}
+		if filename == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return fmt.Errorf("stdin can only be given once with --filename -")
+	}
+
+	if v.options.Wait {
+		return fmt.Errorf("--wait cannot be used with --filename, manifests are not rolled out")
+	}
+
+	return nil
+}
+
+// validateOutput validates the report output format
+func (v *Validator) validateOutput() error {
+	if v.options.Output == "" {
+		return nil
+	}
+
+	if _, exists := types.ValidOutputFormats[strings.ToLower(v.options.Output)]; !exists {
+		return fmt.Errorf("unsupported --output value: %s (must be one of table, json)", v.options.Output)
+	}
+
 	return nil
 }
 
@@ -73,16 +490,78 @@ func (v *Validator) validateResourceName() error {
 
 // validateImageOptions validates image and tag options
 func (v *Validator) validateImageOptions() error {
-	if v.options.Image == "" && v.options.Tag == "" {
-		return fmt.Errorf("either image name or --tag must be specified")
+	if v.options.Image == "" && v.options.Tag == "" && !v.options.Latest {
+		return fmt.Errorf("either image name, --tag or --latest must be specified")
+	}
+
+	if err := v.validateLatestOptions(); err != nil {
+		return err
 	}
 
 	// Validate tag format if tag is specified
 	if v.options.Tag != "" {
 		if strings.Contains(v.options.Tag, "/") || strings.Contains(v.options.Tag, ":") {
-			return fmt.Errorf("tag should only contain the version/tag part (e.g., 'v1.0.1', '7eeb161'), not a full image name. Use the image argument instead for full image names")
+			return &types.InvalidReferenceError{
+				Reference: v.options.Tag,
+				Reason:    "tag should only contain the version/tag part (e.g., 'v1.0.1', '7eeb161'), not a full image name. Use the image argument instead for full image names",
+			}
 		}
 	}
 
 	return nil
 }
+
+// validateLatestOptions validates the --latest tag resolution options
+func (v *Validator) validateLatestOptions() error {
+	if !v.options.Latest {
+		if v.options.TagPattern != "" || v.options.IncludePreRelease {
+			return fmt.Errorf("--tag-pattern and --include-prerelease can only be used with --latest")
+		}
+		return nil
+	}
+
+	if v.options.Tag != "" {
+		return fmt.Errorf("--tag and --latest cannot be used together")
+	}
+
+	if _, err := semver.ParseConstraint(v.options.LatestConstraint); err != nil {
+		return err
+	}
+
+	if v.options.TagPattern != "" {
+		if _, err := tags.CompileTagPattern(v.options.TagPattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateWaitOptions validates the rollout wait options
+func (v *Validator) validateWaitOptions() error {
+	if v.options.WaitFor != "" {
+		if _, exists := types.ValidWaitConditions[strings.ToLower(v.options.WaitFor)]; !exists {
+			return fmt.Errorf("unsupported --wait-for value: %s (must be one of ready, available, cleanup)", v.options.WaitFor)
+		}
+	}
+
+	if v.options.Progress != "" {
+		if _, exists := types.ValidProgressFormats[strings.ToLower(v.options.Progress)]; !exists {
+			return fmt.Errorf("unsupported --progress value: %s (must be one of human, json)", v.options.Progress)
+		}
+	}
+
+	if v.options.Timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+
+	if v.options.PollInterval < 0 {
+		return fmt.Errorf("--poll-interval must not be negative")
+	}
+
+	if v.options.CleanupGrace < 0 {
+		return fmt.Errorf("--cleanup-grace must not be negative")
+	}
+
+	return nil
+}